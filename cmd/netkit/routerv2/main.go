@@ -11,8 +11,7 @@ import (
 func main() {
 	r := netkit.NewRouterV2()
 
-	// this works
-	r.Get("/", netkit.CORSHandler(nil).ServeHTTP)
+	r.Use(netkit.CORSMiddleware(nil))
 
 	r.Get("/metrics", r.MetricsHandler)
 	r.Get("/home", handleHome)
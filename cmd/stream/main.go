@@ -4,6 +4,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/Jonny-Burkholder/streaming-example/internal/handler"
 	"github.com/Jonny-Burkholder/streaming-example/pkg/netkit"
@@ -36,21 +37,43 @@ func main() {
 		}
 	})
 
-	cors := netkit.CORSHandler(nil) // nil = default config
-	r.Get("/", cors.ServeHTTP)      // this should handle cors site wide, I *THINK*
-	// but it is just an example, so you don't have to use it, I just put it here in
-	// case you wanted to play around with it.
+	// handle cors site wide (nil = default config). It is just an example, so you
+	// don't have to use it, I just put it here in case you wanted to play around with it.
+	r.Use(netkit.CORSMiddleware(nil))
+
+	// clients that can not change their URLs pick the version with the
+	// Accept-Version or the Accept header instead of the group prefix
+	versions := netkit.NewVersions(&netkit.VersionConfig{Vendor: "stream", Default: "2"})
+	versions.Deprecate("1", time.Time{}, time.Time{})
 
 	v1 := r.NewGroup("v1")
-	v1.Get("/audio", handler.FileHandlerV1(audio)) // should really be adding the headers somewhere else
-	v1.Get("/video", handler.FileHandlerV1(video))
-	v1.Get("/image", handler.FileHandlerV1(image))
+	v1.Use(versions.Middleware("1"))
+	v1.Get("/audio", handler.FileHandlerV1(audio), netkit.WithName("v1.audio"), netkit.WithSummary("Stream the audio file"), netkit.WithTags("v1")) // should really be adding the headers somewhere else
+	v1.Get("/video", handler.FileHandlerV1(video), netkit.WithName("v1.video"), netkit.WithSummary("Stream the video file"), netkit.WithTags("v1"))
+	v1.Get("/image", handler.FileHandlerV1(image), netkit.WithName("v1.image"), netkit.WithSummary("Serve the image file"), netkit.WithTags("v1"))
 
 	v2 := r.NewGroup("v2")
+	v2.Use(versions.Middleware("2"))
 	// ideally this would include either a path variable or a query param to select a
 	// specific song, but this is just an example and I'm too lazy lol
-	v2.Get("/audio", http.HandlerFunc(handler.AudioHandlerV2))
-	v2.Get("/video", http.HandlerFunc(handler.ImageHandlerV2))
+	v2.Get("/audio", http.HandlerFunc(handler.AudioHandlerV2), netkit.WithName("v2.audio"), netkit.WithSummary("Stream the audio file in chunks"), netkit.WithTags("v2"))
+	v2.Get("/video", http.HandlerFunc(handler.ImageHandlerV2), netkit.WithName("v2.video"), netkit.WithSummary("Stream the video file in chunks"), netkit.WithTags("v2"))
+
+	r.Get("/audio", versions.Handler(netkit.Versioned{
+		"1": handler.FileHandlerV1(audio),
+		"2": http.HandlerFunc(handler.AudioHandlerV2),
+	}).ServeHTTP, netkit.WithName("audio"), netkit.WithSummary("Stream the audio file, as the version in the Accept-Version header"))
+	r.Get("/video", versions.Handler(netkit.Versioned{
+		"1": handler.FileHandlerV1(video),
+		"2": http.HandlerFunc(handler.ImageHandlerV2),
+	}).ServeHTTP, netkit.WithName("video"), netkit.WithSummary("Stream the video file, as the version in the Accept-Version header"))
+
+	// the API docs are generated from the routes above, so they never drift
+	r.Get("/openapi.json", netkit.OpenAPIHandler(r, netkit.OpenAPIInfo{Title: "stream", Version: "1.0.0"}).ServeHTTP)
+
+	if err := r.Validate(); err != nil {
+		log.Panic(err)
+	}
 
 	log.Println("Now serving on port 8080")
 
@@ -2,6 +2,7 @@ package netkit
 
 import (
 	"net/http"
+	"sync/atomic"
 )
 
 // Middleware is a piece of middleware.
@@ -58,3 +59,38 @@ func (c *Chain) Append(mw ...Middleware) *Chain {
 func (c *Chain) Extend(chain *Chain) *Chain {
 	return c.Append(chain.mw...)
 }
+
+// chained is a handler that is served behind the middleware of a chain that
+// can change, such as the middleware of a router or of a group. The handler
+// that the chain builds around it is kept until the chain changes, so that
+// requests do not build it again.
+type chained struct {
+	next  http.Handler
+	built atomic.Pointer[builtChain]
+}
+
+// builtChain is the handler that a chain built around the next handler.
+type builtChain struct {
+	chain   *Chain
+	handler http.Handler
+}
+
+func newChained(next http.Handler) *chained {
+	return &chained{next: next}
+}
+
+// then returns the next handler behind the middleware of the chain. The
+// handler is built the first time the chain is served, and then reused for
+// as long as the chain stays the same, which it does until middleware is
+// added. Chains are never changed in place, Append returns a new one.
+func (c *chained) then(chain *Chain) http.Handler {
+	if len(chain.mw) == 0 {
+		return c.next
+	}
+	if b := c.built.Load(); b != nil && b.chain == chain {
+		return b.handler
+	}
+	b := &builtChain{chain: chain, handler: chain.Then(c.next)}
+	c.built.Store(b)
+	return b.handler
+}
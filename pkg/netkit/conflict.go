@@ -0,0 +1,148 @@
+package netkit
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Jonny-Burkholder/streaming-example/pkg/trees/radix"
+)
+
+// Registration describes a route, along with the place it was registered.
+type Registration struct {
+	Method  string
+	Pattern string
+	Site    string // file:line of the call that registered the route
+}
+
+func (r Registration) String() string {
+	return fmt.Sprintf("%s %s (registered at %s)", r.Method, r.Pattern, r.Site)
+}
+
+// ConflictError reports two routes that conflict with each other. Handle
+// panics with a *ConflictError when a route is registered for a method
+// and pattern that is already taken, and Validate reports every pair of
+// routes that may match the same request.
+type ConflictError struct {
+	Route    Registration // the route that was registered last
+	Existing Registration // the route it conflicts with
+	Reason   string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("netkit: %s conflicts with %s: %s", e.Route, e.Existing, e.Reason)
+}
+
+// ConflictErrors is the list of conflicts returned by Validate.
+type ConflictErrors []*ConflictError
+
+func (es ConflictErrors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+const (
+	reasonDuplicate = "both patterns match exactly the same paths"
+	reasonOverlap   = "some paths match both patterns, so precedence decides which route serves them"
+)
+
+// duplicateError returns the error for registering e, which has the same
+// method and the same shape as the existing entry.
+func duplicateError(e, existing routeEntry) *ConflictError {
+	return &ConflictError{
+		Route:    e.registration(),
+		Existing: existing.registration(),
+		Reason:   reasonDuplicate,
+	}
+}
+
+// netkitPrefix is the prefix of the names of the functions in this package.
+var netkitPrefix = reflect.TypeOf(Router{}).PkgPath() + "."
+
+// registrations counts the routes that have been registered, so that the
+// entries can be put back in the order they were registered in.
+var registrations atomic.Uint64
+
+// callerSite returns the file and line of the first caller outside of
+// netkit, which is where a route is being registered.
+func callerSite() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, netkitPrefix) || strings.HasSuffix(f.File, "_test.go") {
+			return fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+// findConflicts returns every pair of entries whose methods may be the
+// same, and whose patterns may match the same path. Entries that share a
+// shape were already checked when they were registered, and implicit
+// entries, such as the roots of groups, are left out. When prefixes is
+// true, static patterns ending in a '/' are treated as prefixes.
+func findConflicts(es []routeEntry, prefixes bool) error {
+	es = append([]routeEntry(nil), es...)
+	sort.Slice(es, func(i, j int) bool { return es[i].seq < es[j].seq })
+	parts := make([][]part, len(es))
+	shapes := make([]string, len(es))
+	for i, e := range es {
+		pat, _ := CompilePattern(e.pattern)
+		pat = pat.variants()[e.variant]
+		parts[i] = pat.parts(prefixes && e.variant == 0)
+		shapes[i] = pat.shape()
+	}
+	var errs ConflictErrors
+	for j := range es {
+		for i := 0; i < j; i++ {
+			if es[i].implicit || es[j].implicit || shapes[i] == shapes[j] {
+				continue
+			}
+			if !sameMethod(es[i].method, es[j].method) || !overlaps(parts[i], parts[j]) {
+				continue
+			}
+			errs = append(errs, &ConflictError{
+				Route:    es[j].registration(),
+				Existing: es[i].registration(),
+				Reason:   reasonOverlap,
+			})
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// sameMethod reports whether a request could be routed to both methods.
+func sameMethod(m1, m2 string) bool {
+	return m1 == m2 || m1 == "*" || m2 == "*"
+}
+
+// overlaps reports whether some path could match both a and b. Two params
+// are always assumed to overlap, since their constraints can not be
+// compared.
+func overlaps(a, b []part) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		x, y := a[i], b[i]
+		switch {
+		case x.kind == radix.CatchAll || y.kind == radix.CatchAll:
+			return true
+		case x.kind == radix.Static && !y.accepts(x.text):
+			return false
+		case y.kind == radix.Static && !x.accepts(y.text):
+			return false
+		}
+	}
+	return len(a) == len(b)
+}
@@ -0,0 +1,116 @@
+package netkit
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Jonny-Burkholder/streaming-example/pkg/assert"
+)
+
+func TestOverlaps(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		prefixes bool
+		overlap  bool
+	}{
+		{"/users/:id", "/users/new", false, true},
+		{"/users/{id:[0-9]+}", "/users/new", false, false},
+		{"/users/:id", "/users/:name", false, true},
+		{"/users/:id", "/users/:id/info", false, false},
+		{"/users/:id/info", "/users/new/info", false, true},
+		{"/users/:id/info", "/users/new/edit", false, false},
+		{"/files/*path", "/files/:name", false, true},
+		{"/files/*path", "/files", false, false},
+		{"/files/*path", "/files/", false, true},
+		{"/files/", "/files/a/b", false, false},
+		{"/files/", "/files/a/b", true, true},
+		{"/audio", "/video", false, false},
+		{"/a/:x/b", "/a/b/:y", false, true},
+	}
+	for _, tt := range tests {
+		a, err := CompilePattern(tt.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := CompilePattern(tt.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := overlaps(a.parts(tt.prefixes), b.parts(tt.prefixes)); got != tt.overlap {
+			t.Errorf("overlaps(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.overlap)
+		}
+		if got := overlaps(b.parts(tt.prefixes), a.parts(tt.prefixes)); got != tt.overlap {
+			t.Errorf("overlaps(%q, %q) = %v, want %v", tt.b, tt.a, got, tt.overlap)
+		}
+	}
+}
+
+// registerConflict registers the patterns, and returns the value that the
+// last registration panicked with.
+func registerConflict(r RouterInterface, patterns ...string) (v any) {
+	defer func() {
+		v = recover()
+	}()
+	for _, p := range patterns {
+		r.Get(p, paramsHandler(p))
+	}
+	return nil
+}
+
+func TestHandle_Conflict(t *testing.T) {
+	for _, r := range []RouterInterface{newTestRouter(), NewRouterV2()} {
+		v := registerConflict(r, "/users/:id", "/users/{uid}")
+		err, ok := v.(*ConflictError)
+		if !ok {
+			t.Fatalf("%T: expected a *ConflictError, got %v", r, v)
+		}
+		assert.Equal(t, "/users/{uid}", err.Route.Pattern)
+		assert.Equal(t, "/users/:id", err.Existing.Pattern)
+		assert.Equal(t, "GET", err.Existing.Method)
+		if !strings.Contains(err.Existing.Site, "conflict_test.go:") || !strings.Contains(err.Route.Site, "conflict_test.go:") {
+			t.Errorf("%T: expected the sites to point at the test, got %q and %q", r, err.Existing.Site, err.Route.Site)
+		}
+
+		// the failed registration must leave the routes as they were
+		assert.Equal(t, "/users/:id", serve(r, "GET", "/users/42").Body.String())
+		assert.Equal(t, nil, registerConflict(r, "/users/{id:[0-9]+}"))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	rm := newTestRouter()
+	v1 := rm.NewGroup("v1")
+	v1.Get("/users/:id", paramsHandler("user"))
+	v1.Post("/users/new", paramsHandler("new"))
+	rm.Get("/files/*path", paramsHandler("files"))
+	rm.Get("/files/:name", paramsHandler("file"))
+
+	rt := NewRouterV2()
+	v2 := rt.NewGroup("v1")
+	v2.Get("/users/:id", paramsHandler("user"))
+	v2.Post("/users/new", paramsHandler("new"))
+	rt.Get("/files/*path", paramsHandler("files"))
+	rt.Get("/files/:name", paramsHandler("file"))
+
+	for _, r := range []RouterInterface{rm, rt} {
+		// different methods do not conflict
+		if err := r.Validate(); err == nil || len(err.(ConflictErrors)) != 1 {
+			t.Fatalf("%T: expected a single conflict, got %v", r, err)
+		}
+		r.Get("/v1/users/new", paramsHandler("new"))
+		err := r.Validate()
+		var errs ConflictErrors
+		if !errors.As(err, &errs) {
+			t.Fatalf("%T: expected ConflictErrors, got %v", r, err)
+		}
+		assert.Equal(t, 2, len(errs))
+		assert.Equal(t, "/files/*path", errs[0].Existing.Pattern)
+		assert.Equal(t, "/files/:name", errs[0].Route.Pattern)
+		assert.Equal(t, "/v1/users/:id", errs[1].Existing.Pattern)
+		assert.Equal(t, "/v1/users/new", errs[1].Route.Pattern)
+	}
+	if err := NewRouterV2().Validate(); err != nil {
+		t.Errorf("expected no conflicts, got %v", err)
+	}
+}
@@ -121,3 +121,24 @@ skipSetDefaults:
 	}
 	return http.HandlerFunc(fn)
 }
+
+// CORSMiddleware returns middleware that adds the CORS headers to every
+// response, and answers pre-flight requests on its own so that they never
+// reach the router. It is meant to be installed router wide with Use.
+func CORSMiddleware(c *CORSConfig) Middleware {
+	cors := CORSHandler(c)
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions {
+				if r.Header.Get(HeaderAccessControlRequestMethod) != "" {
+					cors.ServeHTTP(w, r)
+					return
+				}
+			} else {
+				cors.ServeHTTP(w, r)
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
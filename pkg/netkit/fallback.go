@@ -0,0 +1,203 @@
+package netkit
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// NotFoundHandler sets the handler for the requests whose path does not match
+// any route. Router has the same option in its Config, and groups can set a
+// handler of their own, see RouterV2Group.NotFound.
+func NotFoundHandler(h http.Handler) RouterV2Option {
+	return func(rt *RouterV2) {
+		rt.fallbacks.notFound = h
+	}
+}
+
+// MethodNotAllowedHandler sets the handler for the requests whose path matches
+// a route that has no handler for the method of the request. The Allow header
+// is set before the handler is called. Router has the same option in its
+// Config, and groups can set a handler of their own.
+func MethodNotAllowedHandler(h http.Handler) RouterV2Option {
+	return func(rt *RouterV2) {
+		rt.fallbacks.methodNotAllowed = h
+	}
+}
+
+// UnsupportedMediaTypeHandler sets the handler for the requests whose path
+// and method match a route whose ContentType matchers turned the request
+// away, see MatchError. Without one, the response tells what the routes
+// require. Router has the same option in its Config, and groups can set a
+// handler of their own.
+func UnsupportedMediaTypeHandler(h http.Handler) RouterV2Option {
+	return func(rt *RouterV2) {
+		rt.fallbacks.unsupportedMediaType = h
+	}
+}
+
+// PanicHandler sets the function that is called with the value that was
+// recovered when a handler or a middleware panics while serving a request.
+// Without one, the panic is left to the http.Server. Router has the same
+// option in its Config, and groups can set a function of their own.
+func PanicHandler(fn func(http.ResponseWriter, *http.Request, any)) RouterV2Option {
+	return func(rt *RouterV2) {
+		rt.fallbacks.panic = fn
+	}
+}
+
+// fallbacks are the handlers for the requests that no route serves, and
+// for the requests whose handler panics. A nil field means the default.
+type fallbacks struct {
+	notFound             http.Handler
+	methodNotAllowed     http.Handler
+	unsupportedMediaType http.Handler
+	panic                func(http.ResponseWriter, *http.Request, any)
+}
+
+// or fills in the fields of f that are nil with those of other.
+func (f fallbacks) or(other fallbacks) fallbacks {
+	if f.notFound == nil {
+		f.notFound = other.notFound
+	}
+	if f.methodNotAllowed == nil {
+		f.methodNotAllowed = other.methodNotAllowed
+	}
+	if f.unsupportedMediaType == nil {
+		f.unsupportedMediaType = other.unsupportedMediaType
+	}
+	if f.panic == nil {
+		f.panic = other.panic
+	}
+	return f
+}
+
+func (f fallbacks) notFoundHandler() http.Handler {
+	if f.notFound == nil {
+		return http.NotFoundHandler()
+	}
+	return f.notFound
+}
+
+func (f fallbacks) methodNotAllowedHandler(allow string) http.Handler {
+	if f.methodNotAllowed == nil {
+		return handleMethodNotAllowed(allow)
+	}
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(HeaderAllow, allow)
+			f.methodNotAllowed.ServeHTTP(w, r)
+		},
+	)
+}
+
+// failed returns the handler for a request whose path matches the table,
+// but that no entry of the table serves, see methodTable.find. When the
+// matchers of the entries turned the request away, it is answered with the
+// error, which tells the client what the routes require, unless a not found
+// or an unsupported media type handler is set. Those can tell why with
+// MatchError.
+func (f fallbacks) failed(table *methodTable, err error) http.Handler {
+	unmatched, ok := err.(*unmatchedError)
+	if !ok {
+		return f.methodNotAllowedHandler(table.allow())
+	}
+	next := f.notFound
+	if unmatched.code == http.StatusUnsupportedMediaType {
+		next = f.unsupportedMediaType
+	}
+	if next == nil {
+		return unmatched
+	}
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), matchErrorContextKey, err)))
+		},
+	)
+}
+
+// recover calls the panic handler with the value of a panic, if there is
+// one. http.ErrAbortHandler is passed on, since it is meant for the server.
+func (f fallbacks) recover(w http.ResponseWriter, r *http.Request) {
+	err := recover()
+	if err == nil {
+		return
+	}
+	if err == http.ErrAbortHandler || f.panic == nil {
+		panic(err)
+	}
+	f.panic(w, r, err)
+}
+
+// groupFallbacks are the fallbacks of the group with the prefix.
+type groupFallbacks struct {
+	prefix string
+	fallbacks
+}
+
+// fallbackSet holds the fallbacks that groups have set, ordered by the length
+// of their prefixes, longest first. It is part of the routing snapshot, so it
+// is never changed in place.
+type fallbackSet []groupFallbacks
+
+// set returns a copy of the set, with fn applied to the fallbacks of the
+// group with the prefix.
+func (fs fallbackSet) set(prefix string, fn func(*fallbacks)) fallbackSet {
+	prefix = strings.TrimSuffix(prefix, "/")
+	c := append(fallbackSet(nil), fs...)
+	for i := range c {
+		if c[i].prefix == prefix {
+			fn(&c[i].fallbacks)
+			return c
+		}
+	}
+	i := 0
+	for i < len(c) && len(c[i].prefix) >= len(prefix) {
+		i++
+	}
+	g := groupFallbacks{prefix: prefix}
+	fn(&g.fallbacks)
+	c = append(c, groupFallbacks{})
+	copy(c[i+1:], c[i:])
+	c[i] = g
+	return c
+}
+
+// remove returns a copy of the set, without the fallbacks of the groups at
+// or below the prefix.
+func (fs fallbackSet) remove(prefix string) fallbackSet {
+	prefix = strings.TrimSuffix(prefix, "/")
+	var c fallbackSet
+	for _, g := range fs {
+		if !inGroup(prefix, g.prefix) {
+			c = append(c, g)
+		}
+	}
+	return c
+}
+
+// resolve returns the fallbacks for the path. Each fallback is taken from
+// the innermost group around the path that set it, and otherwise from def.
+func (fs fallbackSet) resolve(def fallbacks, path string) fallbacks {
+	var f fallbacks
+	for _, g := range fs {
+		if inGroup(g.prefix, path) {
+			f = f.or(g.fallbacks)
+		}
+	}
+	return f.or(def)
+}
+
+// recovers reports whether a panic handler is set anywhere, so that requests
+// only pay for recovering when they need to.
+func (fs fallbackSet) recovers(def fallbacks) bool {
+	if def.panic != nil {
+		return true
+	}
+	for _, g := range fs {
+		if g.panic != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package netkit
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Jonny-Burkholder/streaming-example/pkg/assert"
+)
+
+// render returns a handler that writes the format and the status code.
+func render(format string, code int) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(code)
+			fmt.Fprintf(w, "%s %d", format, code)
+		},
+	)
+}
+
+// renderPanic writes the format and the recovered value.
+func renderPanic(format string) func(http.ResponseWriter, *http.Request, any) {
+	return func(w http.ResponseWriter, r *http.Request, err any) {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "%s %v", format, err)
+	}
+}
+
+func panicHandler(w http.ResponseWriter, r *http.Request) {
+	panic("boom")
+}
+
+func TestFallbacks(t *testing.T) {
+	rm := NewRouter(&Config{
+		LoggingLevel:            LevelOff,
+		NotFoundHandler:         render("html", 404),
+		MethodNotAllowedHandler: render("html", 405),
+		PanicHandler:            renderPanic("html"),
+	})
+	v1 := rm.NewGroup("v1")
+	v1.NotFound(render("json", 404))
+	v1.MethodNotAllowed(render("json", 405))
+	v1.OnPanic(renderPanic("json"))
+	admin := v1.NewGroup("admin")
+	admin.NotFound(render("text", 404))
+
+	rt := NewRouterV2(
+		NotFoundHandler(render("html", 404)),
+		MethodNotAllowedHandler(render("html", 405)),
+		PanicHandler(renderPanic("html")),
+	)
+	v2 := rt.NewGroup("v1")
+	v2.NotFound(render("json", 404))
+	v2.MethodNotAllowed(render("json", 405))
+	v2.OnPanic(renderPanic("json"))
+	admin2 := v2.NewGroup("admin")
+	admin2.NotFound(render("text", 404))
+
+	for _, g := range []RouterGroup{v1, admin, v2, admin2} {
+		g.Get("/audio/:id", paramsHandler("track", "id"))
+		g.Get("/panic", panicHandler)
+	}
+	rm.Get("/ping", paramsHandler("ping"))
+	rm.Get("/panic", panicHandler)
+	rt.Get("/ping", paramsHandler("ping"))
+	rt.Get("/panic", panicHandler)
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+		body   string
+		allow  string
+	}{
+		{"GET", "/missing", 404, "html 404", ""},
+		{"POST", "/ping", 405, "html 405", "GET, HEAD, OPTIONS"},
+		{"GET", "/panic", 500, "html boom", ""},
+		{"GET", "/v1/missing", 404, "json 404", ""},
+		{"POST", "/v1/audio/42", 405, "json 405", "GET, HEAD, OPTIONS"},
+		{"GET", "/v1/panic", 500, "json boom", ""},
+		{"GET", "/v1/audio/42", 200, "track id=42", ""},
+		// the admin group only overrides the not found handler
+		{"GET", "/v1/admin/missing", 404, "text 404", ""},
+		{"POST", "/v1/admin/audio/42", 405, "json 405", "GET, HEAD, OPTIONS"},
+		{"GET", "/v1/admin/panic", 500, "json boom", ""},
+		// a path that only shares a prefix with the group is not in it
+		{"GET", "/v1x/missing", 404, "html 404", ""},
+	}
+	for _, r := range []http.Handler{rm, rt} {
+		for _, tt := range tests {
+			w := serve(r, tt.method, tt.path)
+			assert.Equal(t, tt.code, w.Code)
+			assert.Equal(t, tt.body, w.Body.String())
+			assert.Equal(t, tt.allow, w.Header().Get(HeaderAllow))
+		}
+	}
+
+	// the root of a Router group still answers for the group
+	assert.Equal(t, "group: /v1", serve(rm, "GET", "/v1/").Body.String())
+}
+
+func TestFallbacks_Defaults(t *testing.T) {
+	rm := newTestRouter()
+	rm.NewGroup("v1").Get("/ping", paramsHandler("ping"))
+	rt := NewRouterV2()
+	rt.NewGroup("v1").Get("/ping", paramsHandler("ping"))
+
+	for _, r := range []http.Handler{rm, rt} {
+		w := serve(r, "GET", "/v1/missing")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "404 page not found\n", w.Body.String())
+		w = serve(r, "POST", "/v1/ping")
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+		assert.Equal(t, "GET, HEAD, OPTIONS", w.Header().Get(HeaderAllow))
+	}
+}
+
+func TestFallbacks_AbortHandler(t *testing.T) {
+	rt := NewRouterV2(PanicHandler(renderPanic("html")))
+	rt.Get("/abort", func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+	defer func() {
+		assert.Equal(t, any(http.ErrAbortHandler), recover())
+	}()
+	serve(rt, "GET", "/abort")
+}
+
+func TestFallbacks_RemoveGroup(t *testing.T) {
+	rt := NewRouterV2()
+	v1 := rt.NewGroup("v1")
+	v1.NotFound(render("json", 404))
+	assert.Equal(t, "json 404", serve(rt, "GET", "/v1/missing").Body.String())
+
+	rt.RemoveGroup("v1")
+	assert.Equal(t, "404 page not found\n", serve(rt, "GET", "/v1/missing").Body.String())
+}
+
+func TestFallbacks_Logging(t *testing.T) {
+	rm := NewRouter(&Config{
+		LoggingLevel: LevelInfo,
+		PanicHandler: renderPanic("html"),
+	})
+	var logs strings.Builder
+	rm.logger.SetOutput(&logs)
+	v1 := rm.NewGroup("v1")
+	v1.OnPanic(renderPanic("json"))
+	v1.Get("/panic", panicHandler)
+	rm.Get("/panic", panicHandler)
+	rm.Get("/abort", func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+
+	// the panic is logged, and then handled by the panic handlers
+	w := serve(rm, "GET", "/panic")
+	assert.Equal(t, 500, w.Code)
+	assert.Equal(t, "html boom", w.Body.String())
+	assert.Equal(t, "json boom", serve(rm, "GET", "/v1/panic").Body.String())
+	assert.Equal(t, true, strings.Contains(logs.String(), "err: boom"))
+
+	// http.ErrAbortHandler still aborts the response
+	func() {
+		defer func() {
+			assert.Equal(t, any(http.ErrAbortHandler), recover())
+		}()
+		serve(rm, "GET", "/abort")
+	}()
+
+	// without a panic handler, the panic is logged and answered with a 500
+	h := HandleWithLogging(rm.logger, http.HandlerFunc(panicHandler))
+	assert.Equal(t, 500, serve(h, "GET", "/panic").Code)
+}
+
+func TestFallbacks_LoggingWithoutPanicHandler(t *testing.T) {
+	rm := NewRouter(&Config{LoggingLevel: LevelInfo})
+	var logs strings.Builder
+	rm.logger.SetOutput(&logs)
+	v1 := rm.NewGroup("v1")
+	v1.OnPanic(renderPanic("json"))
+	v1.Get("/panic", panicHandler)
+	rm.Get("/panic", panicHandler)
+
+	// only the group recovers its panics, the others are answered by the
+	// logging with a 500
+	w := serve(rm, "GET", "/panic")
+	assert.Equal(t, 500, w.Code)
+	assert.Equal(t, "", w.Body.String())
+	assert.Equal(t, "json boom", serve(rm, "GET", "/v1/panic").Body.String())
+	assert.Equal(t, 2, strings.Count(logs.String(), "err: boom"))
+}
@@ -3,22 +3,53 @@ package netkit
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 	"path/filepath"
+	"sync"
 )
 
 type RouterGroup interface {
-	Get(pattern string, handler http.HandlerFunc)
-	Post(pattern string, handler http.HandlerFunc)
-	Put(pattern string, handler http.HandlerFunc)
-	Delete(pattern string, handler http.HandlerFunc)
+	Get(pattern string, handler http.HandlerFunc, opts ...RouteOption)
+	Post(pattern string, handler http.HandlerFunc, opts ...RouteOption)
+	Put(pattern string, handler http.HandlerFunc, opts ...RouteOption)
+	Delete(pattern string, handler http.HandlerFunc, opts ...RouteOption)
+	Patch(pattern string, handler http.HandlerFunc, opts ...RouteOption)
+	Head(pattern string, handler http.HandlerFunc, opts ...RouteOption)
+	Options(pattern string, handler http.HandlerFunc, opts ...RouteOption)
+	Any(pattern string, handler http.HandlerFunc, opts ...RouteOption)
+	URL(name string, pairs ...string) (string, error)
+	Use(mw ...Middleware)
+	Mount(prefix string, handler http.Handler)
+	Replace(method string, pattern string, handler http.Handler, opts ...RouteOption)
+	Unregister(method string, pattern string) bool
+	NotFound(h http.Handler)
+	MethodNotAllowed(h http.Handler)
+	UnsupportedMediaType(h http.Handler)
+	OnPanic(fn func(http.ResponseWriter, *http.Request, any))
 }
 
 type Group struct {
 	mux   *Router
 	group string
+	chain *groupChain
 }
 
+// NewGroup returns the group for the prefix, and creates it if it does not
+// exist yet, so calling it twice with the same prefix returns the same group.
 func (rm *Router) NewGroup(group string) *Group {
+	return rm.newGroup(group, nil)
+}
+
+// NewGroup returns a group nested inside g, so that g.NewGroup("admin")
+// on the group "/v1" shares the prefix "/v1/admin". The middleware of g
+// runs in front of the middleware of the nested group.
+func (g *Group) NewGroup(group string) *Group {
+	return g.mux.newGroup(join(g.group, group), g.chain)
+}
+
+// newGroup returns the group for the prefix, and creates it nested inside
+// the group with the parent chain if it does not exist yet.
+func (rm *Router) newGroup(group string, parent *groupChain) *Group {
 	// standardize the group path
 	if len(group) > 0 && group[0] != '/' {
 		group = "/" + group
@@ -26,17 +57,67 @@ func (rm *Router) NewGroup(group string) *Group {
 	if group[len(group)-1] == '/' {
 		group = group[:len(group)-1]
 	}
-	// create a new group
-	g := &Group{
-		mux:   rm,
-		group: group,
+	rm.lock.Lock()
+	g, found := rm.groups[group]
+	if !found {
+		// create a new group
+		g = &Group{
+			mux:   rm,
+			group: group,
+			chain: newGroupChain(parent),
+		}
+		rm.groups[group] = g
+	}
+	rm.lock.Unlock()
+	if !found {
+		// register base path for group
+		rm.Handle("*", join(group, "/"), g.handleGroupRoot(), implicit())
 	}
-	// register base path for group
-	rm.Handle("*", join(group, "/"), http.StripPrefix(g.group, g.handleGroupRoot()))
-	// return new group
 	return g
 }
 
+// Mount hands every request below the prefix of the group joined with
+// the prefix to the handler, see Router.Mount.
+func (g *Group) Mount(prefix string, handler http.Handler) {
+	g.mux.mount(join(g.group, prefix), handler, groupOptions(g.group, g.chain, nil))
+}
+
+// strippedRequest is the storage for the copy of a request that a group
+// passes to its handlers, with the prefix of the group stripped from its
+// path. It is taken from strippedPool while the handler runs, so that
+// groups do not allocate, and put back once it returns, see Params.
+type strippedRequest struct {
+	req http.Request
+	url url.URL
+}
+
+var strippedPool = sync.Pool{
+	New: func() any { return new(strippedRequest) },
+}
+
+// stripGroup works like http.StripPrefix, but the request that it passes
+// to the handler is only valid until the handler returns.
+func stripGroup(prefix string, h http.Handler) http.Handler {
+	if prefix == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, rp, ok := trimPath(r.URL, prefix)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		s := strippedPool.Get().(*strippedRequest)
+		s.url = *r.URL
+		s.url.Path, s.url.RawPath = p, rp
+		s.req = *r
+		s.req.URL = &s.url
+		h.ServeHTTP(w, &s.req)
+		s.req, s.url = http.Request{}, url.URL{}
+		strippedPool.Put(s)
+	})
+}
+
 // join cleans and joins the group path with the pattern and
 // returns the joined string
 func join(group, pattern string) string {
@@ -47,24 +128,114 @@ func join(group, pattern string) string {
 	return s
 }
 
-func (g *Group) Get(pattern string, handler http.HandlerFunc) {
-	g.mux.Handle(http.MethodGet, join(g.group, pattern), http.StripPrefix(g.group, handler))
+func (g *Group) Get(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	g.mux.Handle(http.MethodGet, join(g.group, pattern), stripGroup(g.group, handler), groupOptions(g.group, g.chain, opts)...)
+}
+
+func (g *Group) Post(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	g.mux.Handle(http.MethodPost, join(g.group, pattern), stripGroup(g.group, handler), groupOptions(g.group, g.chain, opts)...)
+}
+
+func (g *Group) Put(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	g.mux.Handle(http.MethodPut, join(g.group, pattern), stripGroup(g.group, handler), groupOptions(g.group, g.chain, opts)...)
+}
+
+func (g *Group) Delete(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	g.mux.Handle(http.MethodDelete, join(g.group, pattern), stripGroup(g.group, handler), groupOptions(g.group, g.chain, opts)...)
+}
+
+func (g *Group) Patch(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	g.mux.Handle(http.MethodPatch, join(g.group, pattern), stripGroup(g.group, handler), groupOptions(g.group, g.chain, opts)...)
+}
+
+func (g *Group) Head(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	g.mux.Handle(http.MethodHead, join(g.group, pattern), stripGroup(g.group, handler), groupOptions(g.group, g.chain, opts)...)
+}
+
+func (g *Group) Options(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	g.mux.Handle(http.MethodOptions, join(g.group, pattern), stripGroup(g.group, handler), groupOptions(g.group, g.chain, opts)...)
+}
+
+func (g *Group) Any(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	g.mux.Handle("*", join(g.group, pattern), stripGroup(g.group, handler), groupOptions(g.group, g.chain, opts)...)
+}
+
+// Replace swaps in the handler for the method and pattern of the group,
+// see Router.Replace.
+func (g *Group) Replace(method string, pattern string, handler http.Handler, opts ...RouteOption) {
+	g.mux.Replace(method, join(g.group, pattern), stripGroup(g.group, handler), groupOptions(g.group, g.chain, opts)...)
 }
 
-func (g *Group) Post(pattern string, handler http.HandlerFunc) {
-	g.mux.Handle(http.MethodPost, join(g.group, pattern), http.StripPrefix(g.group, handler))
+// Unregister removes the handler for the method and pattern of the group,
+// see Router.Unregister.
+func (g *Group) Unregister(method string, pattern string) bool {
+	return g.mux.Unregister(method, join(g.group, pattern))
 }
 
-func (g *Group) Put(pattern string, handler http.HandlerFunc) {
-	g.mux.Handle(http.MethodPut, join(g.group, pattern), http.StripPrefix(g.group, handler))
+// Use appends middleware to the group. Group middleware runs after the
+// middleware of the router, and before the middleware of the route. Like
+// the middleware of the router, it applies to every route of the group and
+// of its nested groups, including those registered before Use is called.
+func (g *Group) Use(mw ...Middleware) {
+	g.mux.lock.Lock()
+	defer g.mux.lock.Unlock()
+	g.chain.use(mw...)
 }
 
-func (g *Group) Delete(pattern string, handler http.HandlerFunc) {
-	g.mux.Handle(http.MethodDelete, join(g.group, pattern), http.StripPrefix(g.group, handler))
+// NotFound sets the handler for the requests below the prefix of the group
+// whose path does not match any route, in place of the NotFoundHandler of
+// the router. Nested groups use it unless they set one of their own.
+func (g *Group) NotFound(h http.Handler) {
+	g.setFallback(func(f *fallbacks) { f.notFound = h })
+}
+
+// MethodNotAllowed sets the handler for the requests below the prefix of
+// the group whose method is not allowed, see NotFound.
+func (g *Group) MethodNotAllowed(h http.Handler) {
+	g.setFallback(func(f *fallbacks) { f.methodNotAllowed = h })
+}
+
+// UnsupportedMediaType sets the handler for the requests below the prefix
+// of the group whose content type no route accepts, see NotFound.
+func (g *Group) UnsupportedMediaType(h http.Handler) {
+	g.setFallback(func(f *fallbacks) { f.unsupportedMediaType = h })
+}
+
+// OnPanic sets the function that is called when the handler of a request
+// below the prefix of the group panics, see NotFound.
+func (g *Group) OnPanic(fn func(http.ResponseWriter, *http.Request, any)) {
+	g.setFallback(func(f *fallbacks) { f.panic = fn })
+}
+
+func (g *Group) setFallback(fn func(*fallbacks)) {
+	g.mux.change(func(rt *routerTable) {
+		rt.groups = rt.groups.set(g.group, fn)
+	})
+}
+
+// URL builds the path of a named route, see Router.URL.
+func (g *Group) URL(name string, pairs ...string) (string, error) {
+	return g.mux.URL(name, pairs...)
+}
+
+// implicit marks the root of a group, which is registered by NewGroup, so
+// that Validate does not report it as overlapping the routes of the group.
+func implicit() RouteOption {
+	return func(e *routeEntry) {
+		e.implicit = true
+	}
 }
 
+// handleGroupRoot answers the root of the group. Since the root is a prefix
+// pattern, it also receives every path below the group that does not match
+// a route, and those are handed to the not found handler of the router.
 func (g *Group) handleGroupRoot() http.Handler {
+	root := join(g.group, "/")
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != root {
+			g.mux.notFound(g.mux.routes.Load(), r).ServeHTTP(w, r)
+			return
+		}
 		WriteRaw(w, r, 200, []byte(fmt.Sprintf("group: %s", g.group)))
 	})
 }
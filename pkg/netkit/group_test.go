@@ -0,0 +1,158 @@
+package netkit
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Jonny-Burkholder/streaming-example/pkg/assert"
+)
+
+// pathHandler writes the path of the request as the handler sees it.
+func pathHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "%s %s", r.Method, r.URL.Path)
+}
+
+func TestRouter_NestedGroup(t *testing.T) {
+	rm := newTestRouter()
+	v1 := rm.NewGroup("v1")
+	v1.Use(trace("v1"))
+	admin := v1.NewGroup("admin")
+	admin.Use(trace("admin"))
+	admin.Get("/users/:id", paramsHandler("user", "id"), WithName("v1.admin.user"))
+
+	w := serve(rm, "GET", "/v1/admin/users/42")
+	assert.Equal(t, "user id=42", w.Body.String())
+	assert.Equal(t, []string{"v1", "admin"}, w.Header().Values("X-Trace"))
+
+	u, err := rm.URL("v1.admin.user", "id", "7")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	assert.Equal(t, "/v1/admin/users/7", u)
+}
+
+func TestRouterV2_NestedGroup(t *testing.T) {
+	rt := NewRouterV2()
+	v1 := rt.NewGroup("v1")
+	admin := v1.NewGroup("admin")
+	admin.Get("/users/:id", paramsHandler("user", "id"))
+
+	w := serve(rt, "GET", "/v1/admin/users/42")
+	assert.Equal(t, "user id=42", w.Body.String())
+	if v1.NewGroup("admin") != admin {
+		t.Errorf("expected NewGroup to return the existing group")
+	}
+}
+
+func TestNewGroup_Existing(t *testing.T) {
+	rm := newTestRouter()
+	v1 := rm.NewGroup("v1")
+	admin := v1.NewGroup("admin")
+	v1.Get("/users", paramsHandler("users"))
+
+	// the same prefix returns the same group, however it is spelled
+	for _, g := range []*Group{rm.NewGroup("/v1/"), rm.NewGroup("v1")} {
+		if g != v1 {
+			t.Errorf("Router: expected NewGroup to return the existing group")
+		}
+	}
+	if v1.NewGroup("admin") != admin || rm.NewGroup("v1/admin") != admin {
+		t.Errorf("Router: expected NewGroup to return the existing nested group")
+	}
+	assert.Equal(t, "users", serve(rm, "GET", "/v1/users").Body.String())
+
+	rt := NewRouterV2()
+	v2 := rt.NewGroup("v1")
+	admin2 := v2.NewGroup("admin")
+	if rt.NewGroup("/v1/") != v2 || rt.NewGroup("v1/admin") != admin2 {
+		t.Errorf("RouterV2: expected NewGroup to return the existing group")
+	}
+
+	// once a group is removed, NewGroup starts out with a fresh one
+	rm.RemoveGroup("v1")
+	if rm.NewGroup("v1") == v1 {
+		t.Errorf("Router: expected a fresh group after RemoveGroup")
+	}
+	assert.Equal(t, "group: /v1", serve(rm, "GET", "/v1/").Body.String())
+}
+
+func TestMount(t *testing.T) {
+	sub := NewRouterV2()
+	sub.Get("/", pathHandler)
+	sub.Get("/tracks/:id", paramsHandler("track", "id"))
+
+	routers := []struct {
+		name string
+		r    RouterInterface
+	}{
+		{"Router", newTestRouter()},
+		{"RouterV2", NewRouterV2()},
+	}
+	for _, rr := range routers {
+		rr.r.Mount("/legacy", http.HandlerFunc(pathHandler))
+		rr.r.Mount("/audio/", sub)
+		rr.r.Get("/legacy/new", paramsHandler("new"))
+
+		tests := []struct {
+			method string
+			path   string
+			code   int
+			body   string
+		}{
+			{"GET", "/legacy", 200, "GET /"},
+			{"POST", "/legacy/", 200, "POST /"},
+			{"DELETE", "/legacy/a/b", 200, "DELETE /a/b"},
+			{"GET", "/legacy/new", 200, "new"},
+			{"GET", "/legacyx", 404, "404 page not found\n"},
+			{"GET", "/audio", 200, "GET /"},
+			{"GET", "/audio/tracks/42", 200, "track id=42"},
+			{"GET", "/audio/missing", 404, "404 page not found\n"},
+		}
+		for _, tt := range tests {
+			w := serve(rr.r, tt.method, tt.path)
+			if w.Code != tt.code || w.Body.String() != tt.body {
+				t.Errorf("%s: %s %s: got %d %q, want %d %q", rr.name, tt.method, tt.path, w.Code, w.Body.String(), tt.code, tt.body)
+			}
+		}
+	}
+}
+
+func TestGroup_Mount(t *testing.T) {
+	rm := newTestRouter()
+	v1 := rm.NewGroup("v1")
+	v1.Use(trace("v1"))
+	v1.Mount("/legacy", http.HandlerFunc(pathHandler))
+
+	w := serve(rm, "GET", "/v1/legacy/files/a.mp3")
+	assert.Equal(t, "GET /files/a.mp3", w.Body.String())
+	assert.Equal(t, []string{"v1"}, w.Header().Values("X-Trace"))
+}
+
+func TestGroup_UseAfterRoutes(t *testing.T) {
+	rm := newTestRouter()
+	v1 := rm.NewGroup("v1")
+	v1.Get("/users", paramsHandler("users"))
+	admin := v1.NewGroup("admin")
+	admin.Get("/stats", paramsHandler("stats"))
+	v1.Use(trace("auth"))
+	admin.Use(trace("admin"))
+
+	rt := NewRouterV2()
+	v2 := rt.NewGroup("v1")
+	v2.Get("/users", paramsHandler("users"))
+	admin2 := v2.NewGroup("admin")
+	admin2.Get("/stats", paramsHandler("stats"))
+	v2.Use(trace("auth"))
+	admin2.Use(trace("admin"))
+
+	for _, r := range []RouterInterface{rm, rt} {
+		assert.Equal(t, []string{"auth"}, serve(r, "GET", "/v1/users").Header().Values("X-Trace"))
+		assert.Equal(t, []string{"auth", "admin"}, serve(r, "GET", "/v1/admin/stats").Header().Values("X-Trace"))
+		for _, ri := range r.Routes() {
+			if ri.Pattern == "/v1/admin/stats" {
+				assert.Equal(t, 2, len(ri.Middleware))
+			}
+		}
+	}
+}
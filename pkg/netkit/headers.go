@@ -125,4 +125,7 @@ const (
 	HeaderXRequestedWith          = "X-Requested-With"
 	HeaderXRobotsTag              = "X-Robots-Tag"
 	HeaderXUACompatible           = "X-UA-Compatible"
+	HeaderAcceptVersion           = "Accept-Version"
+	HeaderDeprecation             = "Deprecation"
+	HeaderSunset                  = "Sunset"
 )
@@ -0,0 +1,217 @@
+package netkit
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// hostLabel is a single, dot separated label of a host pattern.
+type hostLabel struct {
+	text  string
+	kind  int
+	expr  string
+	match func(string) bool
+}
+
+const (
+	labelStatic = iota
+	labelWild
+	labelParam
+)
+
+// hostPattern is a parsed host pattern.
+//
+// A host pattern is a list of labels separated by dots. A label may be
+// literal text, which is compared without regard to case, a "*" which
+// matches any single label, or a parameter written as "{name}", which
+// captures a single label. Just like path parameters, a host parameter
+// may carry a constraint, as in "{tenant:[a-z]+}.example.com".
+type hostPattern struct {
+	raw    string
+	labels []hostLabel
+	names  []string
+}
+
+// parseHost parses the provided host pattern, and returns an error if the
+// pattern is malformed.
+func parseHost(p string) (*hostPattern, error) {
+	if p == "" {
+		return nil, fmt.Errorf("netkit: invalid host %q: empty host", p)
+	}
+	hp := &hostPattern{raw: p}
+	for _, l := range strings.Split(strings.TrimSuffix(p, "."), ".") {
+		switch {
+		case l == "":
+			return nil, fmt.Errorf("netkit: invalid host %q: empty label", p)
+		case l == "*":
+			hp.labels = append(hp.labels, hostLabel{kind: labelWild})
+		case l[0] == '{':
+			if closingBrace(l, 0) != len(l)-1 {
+				return nil, fmt.Errorf("netkit: invalid host %q: parameter must take up a whole label", p)
+			}
+			name, expr := l[1:len(l)-1], ""
+			if k := strings.IndexByte(name, ':'); k != -1 {
+				name, expr = name[:k], name[k+1:]
+			}
+			if err := checkParamName(name); err != nil {
+				return nil, fmt.Errorf("netkit: invalid host %q: %w", p, err)
+			}
+			label := hostLabel{text: name, kind: labelParam}
+			if expr != "" {
+				match, err := compileConstraint(expr)
+				if err != nil {
+					return nil, fmt.Errorf("netkit: invalid host %q: bad constraint for parameter %q: %w", p, name, err)
+				}
+				label.expr = expr
+				label.match = match
+			}
+			hp.labels = append(hp.labels, label)
+			hp.names = append(hp.names, name)
+		default:
+			if strings.ContainsAny(l, "{}*") {
+				return nil, fmt.Errorf("netkit: invalid host %q: bad label %q", p, l)
+			}
+			hp.labels = append(hp.labels, hostLabel{text: l, kind: labelStatic})
+		}
+	}
+	return hp, nil
+}
+
+// match reports whether the host matches the pattern, and returns the
+// values captured by its parameters appended to vals.
+func (hp *hostPattern) match(host string, vals []string) ([]string, bool) {
+	host = strings.TrimSuffix(host, ".")
+	for i, l := range hp.labels {
+		var label string
+		if i == len(hp.labels)-1 {
+			label, host = host, ""
+		} else {
+			j := strings.IndexByte(host, '.')
+			if j == -1 {
+				return nil, false
+			}
+			label, host = host[:j], host[j+1:]
+		}
+		if label == "" || strings.IndexByte(label, '.') != -1 {
+			return nil, false
+		}
+		switch l.kind {
+		case labelStatic:
+			if !strings.EqualFold(label, l.text) {
+				return nil, false
+			}
+		case labelParam:
+			if l.match != nil && !l.match(label) {
+				return nil, false
+			}
+			vals = append(vals, label)
+		}
+	}
+	return vals, true
+}
+
+// rank returns the rank of the label in the precedence rule, lowest first.
+func (l hostLabel) rank() int {
+	switch {
+	case l.kind == labelStatic:
+		return 0
+	case l.kind == labelParam && l.match != nil:
+		return 1
+	case l.kind == labelParam:
+		return 2
+	}
+	return 3
+}
+
+// key tells apart labels of the same rank: static labels by their text,
+// and constrained parameters by their constraints.
+func (l hostLabel) key() string {
+	if l.kind == labelStatic {
+		return strings.ToLower(l.text)
+	}
+	return l.expr
+}
+
+// precedes reports whether the host pattern takes precedence over other,
+// comparing their labels from left to right.
+func (hp *hostPattern) precedes(other *hostPattern) bool {
+	a, b := hp.labels, other.labels
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if ra, rb := a[i].rank(), b[i].rank(); ra != rb {
+			return ra < rb
+		}
+		if ka, kb := a[i].key(), b[i].key(); ka != kb {
+			return ka < kb
+		}
+	}
+	return len(a) > len(b)
+}
+
+// hostRoute is a sub-router that serves the requests for a host pattern.
+type hostRoute struct {
+	host    *hostPattern
+	handler http.Handler
+	chained *chained // the handler behind the middleware of the router
+}
+
+func newHostRoute(host *hostPattern, handler http.Handler) hostRoute {
+	return hostRoute{host: host, handler: handler, chained: newChained(handler)}
+}
+
+// hostRoutes is a list of host routes, which is kept ordered by the
+// precedence of their patterns, so the first match is the one that wins.
+type hostRoutes []hostRoute
+
+// insert returns the list with the host route added in order of precedence.
+func (hs hostRoutes) insert(h hostRoute) hostRoutes {
+	i := 0
+	for i < len(hs) && !h.host.precedes(hs[i].host) {
+		i++
+	}
+	hs = append(hs, hostRoute{})
+	copy(hs[i+1:], hs[i:])
+	hs[i] = h
+	return hs
+}
+
+// match returns the handler of the first host route that matches the host
+// of the request, along with a request that carries the host parameters,
+// which is backed by p.
+func (hs hostRoutes) match(r *http.Request, p *routeParams) (*chained, *http.Request, bool) {
+	if len(hs) == 0 {
+		return nil, r, false
+	}
+	host := stripHostPort(r.Host)
+	for _, h := range hs {
+		if vals, ok := h.host.match(host, p.vals[:0]); ok {
+			p.vals = vals
+			return h.chained, p.request(r, h.host.names, vals), true
+		}
+	}
+	return nil, r, false
+}
+
+// find returns the handler registered for the host pattern, if any.
+func (hs hostRoutes) find(pattern string) (http.Handler, bool) {
+	for _, h := range hs {
+		if h.host.raw == pattern {
+			return h.handler, true
+		}
+	}
+	return nil, false
+}
+
+// stripHostPort returns h without any trailing ":<port>".
+func stripHostPort(h string) string {
+	// If no port on host, return unchanged
+	if strings.IndexByte(h, ':') == -1 {
+		return h
+	}
+	host, _, err := net.SplitHostPort(h)
+	if err != nil {
+		return h // on error, return unchanged
+	}
+	return host
+}
@@ -0,0 +1,107 @@
+package netkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Jonny-Burkholder/streaming-example/pkg/assert"
+)
+
+func TestParseHost(t *testing.T) {
+	tests := []struct {
+		pattern string
+		host    string
+		match   bool
+		vals    []string
+	}{
+		{"media.example.com", "media.example.com", true, nil},
+		{"media.example.com", "MEDIA.Example.com", true, nil},
+		{"media.example.com", "api.example.com", false, nil},
+		{"{tenant}.example.com", "acme.example.com", true, []string{"acme"}},
+		{"{tenant}.example.com", "a.b.example.com", false, nil},
+		{"{tenant}.example.com", "example.com", false, nil},
+		{"{tenant:[a-z]+}.{region}.example.com", "acme.eu.example.com", true, []string{"acme", "eu"}},
+		{"{tenant:[a-z]+}.example.com", "acme1.example.com", false, nil},
+		{"*.example.com", "www.example.com", true, nil},
+		{"*.example.com", "example.com", false, nil},
+		{"example.com", "example.com.", true, nil},
+	}
+	for _, tt := range tests {
+		hp, err := parseHost(tt.pattern)
+		if err != nil {
+			t.Fatalf("parseHost(%q): unexpected error: %s", tt.pattern, err)
+		}
+		vals, ok := hp.match(tt.host, nil)
+		if ok != tt.match {
+			t.Errorf("match(%q, %q): expected %v, got %v", tt.pattern, tt.host, tt.match, ok)
+			continue
+		}
+		assert.Equal(t, tt.vals, vals)
+	}
+	for _, bad := range []string{"", "a..com", "{}.com", "{x.com", "x{y}.com", "{x:(}.com"} {
+		if _, err := parseHost(bad); err == nil {
+			t.Errorf("parseHost(%q): expected an error", bad)
+		}
+	}
+}
+
+func serveHost(h http.Handler, method, host, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, path, nil)
+	r.Host = host
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestRouter_Host(t *testing.T) {
+	rm := newTestRouter()
+	rm.Get("/audio/{id}", paramsHandler("default", "id"))
+	media := rm.Host("media.example.com")
+	media.Get("/audio/{id}", paramsHandler("media", "id"))
+	tenant := rm.Host("{tenant}.example.com")
+	tenant.Get("/audio/{id}", paramsHandler("tenant", "tenant", "id"))
+	if rm.Host("media.example.com") != media {
+		t.Errorf("expected Host to return the same sub-router for the same pattern")
+	}
+
+	tests := []struct {
+		host string
+		code int
+		body string
+	}{
+		{"media.example.com:8080", 200, "media id=1"},
+		{"acme.example.com", 200, "tenant tenant=acme id=1"},
+		{"localhost", 200, "default id=1"},
+	}
+	for _, tt := range tests {
+		w := serveHost(rm, http.MethodGet, tt.host, "/audio/1")
+		assert.Equal(t, tt.code, w.Code)
+		assert.Equal(t, tt.body, w.Body.String())
+	}
+	w := serveHost(rm, http.MethodGet, "media.example.com", "/video/1")
+	assert.Equal(t, 404, w.Code)
+}
+
+func TestRouterV2_Host(t *testing.T) {
+	rt := NewRouterV2()
+	rt.Get("/audio/:id", paramsHandler("default", "id"))
+	api := rt.Host("api.example.com")
+	api.Get("/audio/:id", paramsHandler("api", "id"))
+	tenant := rt.Host("{tenant}.*.com")
+	tenant.Get("/audio/:id", paramsHandler("tenant", "tenant", "id"))
+
+	tests := []struct {
+		host string
+		body string
+	}{
+		{"api.example.com", "api id=1"},
+		{"acme.example.com", "tenant tenant=acme id=1"},
+		{"example.org", "default id=1"},
+	}
+	for _, tt := range tests {
+		w := serveHost(rt, http.MethodGet, tt.host, "/audio/1")
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, tt.body, w.Body.String())
+	}
+}
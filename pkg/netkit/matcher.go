@@ -0,0 +1,195 @@
+package netkit
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Matcher is a condition on a request, besides its method and path, that a
+// route can require with WithMatchers. Several routes can be registered for
+// the same method and pattern, as long as their matchers differ.
+type Matcher struct {
+	desc        string // describes the matcher, and tells matchers apart
+	contentType bool
+	match       func(*http.Request) bool
+}
+
+func (m Matcher) String() string {
+	return m.desc
+}
+
+// Header matches the requests that carry the header with the value, or that
+// carry the header at all if the value is empty.
+func Header(key, value string) Matcher {
+	key = http.CanonicalHeaderKey(key)
+	if value == "" {
+		return Matcher{
+			desc:  "header " + key,
+			match: func(r *http.Request) bool { return len(r.Header.Values(key)) > 0 },
+		}
+	}
+	return Matcher{
+		desc: fmt.Sprintf("header %s: %s", key, value),
+		match: func(r *http.Request) bool {
+			for _, v := range r.Header.Values(key) {
+				if v == value {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// Query matches the requests that have the query parameter with the value,
+// or that have the query parameter at all if the value is empty.
+func Query(key, value string) Matcher {
+	if value == "" {
+		return Matcher{
+			desc:  "query " + key,
+			match: func(r *http.Request) bool { return r.URL.Query().Has(key) },
+		}
+	}
+	return Matcher{
+		desc: fmt.Sprintf("query %s=%s", key, value),
+		match: func(r *http.Request) bool {
+			for _, v := range r.URL.Query()[key] {
+				if v == value {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// ContentType matches the requests whose body has one of the media types,
+// ignoring parameters such as the charset. A type may end in "/*", as in
+// "text/*", to match every subtype. When the only matchers that fail are
+// content types, the request is answered with 415 Unsupported Media Type, see
+// UnsupportedMediaTypeHandler.
+func ContentType(types ...string) Matcher {
+	types = append([]string(nil), types...)
+	for i := range types {
+		types[i] = strings.ToLower(types[i])
+	}
+	return Matcher{
+		desc:        "content type " + strings.Join(types, " or "),
+		contentType: true,
+		match: func(r *http.Request) bool {
+			mt, _, err := mime.ParseMediaType(r.Header.Get(HeaderContentType))
+			if err != nil {
+				return false
+			}
+			for _, t := range types {
+				if t == mt || strings.HasSuffix(t, "/*") && strings.HasPrefix(mt, t[:len(t)-1]) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// MatchFunc matches the requests for which fn returns true. The name
+// describes the matcher when a request is turned away, and tells it apart
+// from the matchers of other routes.
+func MatchFunc(name string, fn func(*http.Request) bool) Matcher {
+	if fn == nil {
+		panic("netkit: nil match func")
+	}
+	return Matcher{desc: name, match: fn}
+}
+
+// WithMatchers requires every one of the matchers to match a request for the
+// route to serve it. The routes of a method and pattern are tried from the
+// one with the most matchers to the one with the fewest, and routes with the
+// same number of matchers are tried in the order of their descriptions, so
+// the order does not depend on the order of registration. The route without
+// matchers, if there is one, is tried last.
+func WithMatchers(ms ...Matcher) RouteOption {
+	return func(e *routeEntry) {
+		e.matchers = append(e.matchers, ms...)
+		sort.Slice(e.matchers, func(i, j int) bool { return e.matchers[i].desc < e.matchers[j].desc })
+	}
+}
+
+// matcherKey returns the key that tells apart the entries of a method by
+// their matchers, which is empty for an entry without matchers.
+func matcherKey(ms []Matcher) string {
+	descs := make([]string, len(ms))
+	for i, m := range ms {
+		descs[i] = m.desc
+	}
+	return strings.Join(descs, " && ")
+}
+
+// precedes reports whether the entry is tried before the other entry of
+// the same method: the entry with more matchers goes first, and entries with
+// as many matchers are ordered by their descriptions.
+func (m routeEntry) precedes(other routeEntry) bool {
+	if len(m.matchers) != len(other.matchers) {
+		return len(m.matchers) > len(other.matchers)
+	}
+	return matcherKey(m.matchers) < matcherKey(other.matchers)
+}
+
+// matches reports whether every matcher of the entry matches the request.
+// Otherwise it returns the first matcher that failed, along with a boolean
+// indicating true if only content type matchers failed.
+func (m routeEntry) matches(r *http.Request) (bool, Matcher, bool) {
+	var failed Matcher
+	onlyContentType := true
+	for _, mt := range m.matchers {
+		if mt.match(r) {
+			continue
+		}
+		if failed.match == nil {
+			failed = mt
+		}
+		onlyContentType = onlyContentType && mt.contentType
+	}
+	return failed.match == nil, failed, onlyContentType
+}
+
+// errMethodNotAllowed is returned by methodTable.find when the table has no
+// entry for the method of the request.
+var errMethodNotAllowed = errors.New("method not allowed")
+
+// unmatchedError is returned by methodTable.find when the table has entries
+// for the method of the request, but the matchers of every one of them
+// failed.
+type unmatchedError struct {
+	code    int
+	reasons []string
+}
+
+func (e *unmatchedError) Error() string {
+	return "no route matches the request: " + strings.Join(e.reasons, "; ")
+}
+
+// matchErrorContextKey is the context key that holds the error of a request
+// that the matchers of every route turned away, see MatchError.
+var matchErrorContextKey = &contextKey{"match error"}
+
+// MatchError returns the error that tells why the matchers of the routes for
+// the path and method of the request turned it away, which lists what each
+// route requires, or nil if they did not. It is meant for the not found and
+// the unsupported media type handlers, which serve those requests.
+func MatchError(r *http.Request) error {
+	if err, ok := r.Context().Value(matchErrorContextKey).(error); ok {
+		return err
+	}
+	return nil
+}
+
+// ServeHTTP answers the request with the code and the reasons of the error.
+// It is the default handler for the requests that the matchers turned away,
+// see fallbacks.failed.
+func (e *unmatchedError) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, e.Error(), e.code)
+}
@@ -0,0 +1,177 @@
+package netkit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Jonny-Burkholder/streaming-example/pkg/assert"
+)
+
+// matcherRoutes returns the routes used by the matcher tests, which are
+// registered in the order given by the caller.
+func matcherRoutes() []func(RouterInterface) {
+	return []func(RouterInterface){
+		func(r RouterInterface) {
+			r.Get("/playlist", paramsHandler("m3u"), WithMatchers(Query("format", "m3u")))
+		},
+		func(r RouterInterface) {
+			r.Get("/playlist", paramsHandler("mobile"), WithMatchers(Header("x-client", "mobile")))
+		},
+		func(r RouterInterface) {
+			r.Get("/playlist", paramsHandler("mobile m3u"), WithMatchers(Query("format", "m3u"), Header("X-Client", "mobile")))
+		},
+		func(r RouterInterface) {
+			r.Get("/playlist", paramsHandler("default"))
+		},
+		func(r RouterInterface) {
+			r.Post("/tracks", paramsHandler("json"), WithMatchers(ContentType("application/json")))
+		},
+		func(r RouterInterface) {
+			r.Post("/tracks", paramsHandler("text"), WithMatchers(ContentType("text/*")))
+		},
+		func(r RouterInterface) {
+			r.Put("/tracks", paramsHandler("put"), WithMatchers(ContentType("application/json"), Header("X-Client", "")))
+		},
+		func(r RouterInterface) {
+			beta := MatchFunc("beta cookie", func(r *http.Request) bool {
+				_, err := r.Cookie("beta")
+				return err == nil
+			})
+			r.Get("/beta", paramsHandler("beta"), WithMatchers(beta))
+		},
+	}
+}
+
+func TestMatchers(t *testing.T) {
+	tests := []struct {
+		method  string
+		path    string
+		headers map[string]string
+		code    int
+		body    string
+	}{
+		{"GET", "/playlist?format=m3u", nil, 200, "m3u"},
+		{"GET", "/playlist?format=m3u", map[string]string{"X-Client": "mobile"}, 200, "mobile m3u"},
+		{"GET", "/playlist", map[string]string{"X-Client": "mobile"}, 200, "mobile"},
+		{"GET", "/playlist?format=pls", nil, 200, "default"},
+		{"HEAD", "/playlist?format=m3u", nil, 200, ""},
+		{"POST", "/tracks", map[string]string{"Content-Type": "application/json; charset=utf-8"}, 200, "json"},
+		{"POST", "/tracks", map[string]string{"Content-Type": "text/plain"}, 200, "text"},
+		{"POST", "/tracks", map[string]string{"Content-Type": "application/xml"}, 415, "no route matches the request: POST /tracks requires content type application/json; POST /tracks requires content type text/*\n"},
+		{"POST", "/tracks", nil, 415, "no route matches the request: POST /tracks requires content type application/json; POST /tracks requires content type text/*\n"},
+		{"PUT", "/tracks", map[string]string{"Content-Type": "application/json"}, 404, "no route matches the request: PUT /tracks requires header X-Client\n"},
+		{"PUT", "/tracks", map[string]string{"Content-Type": "application/json", "X-Client": "web"}, 200, "put"},
+		{"DELETE", "/tracks", nil, 405, "Method Not Allowed\n"},
+		{"GET", "/beta", nil, 404, "no route matches the request: GET /beta requires beta cookie\n"},
+		{"GET", "/beta", map[string]string{"Cookie": "beta=1"}, 200, "beta"},
+	}
+	routes := matcherRoutes()
+	orders := [][]int{{0, 1, 2, 3, 4, 5, 6, 7}, {7, 6, 5, 4, 3, 2, 1, 0}}
+	for _, order := range orders {
+		for _, r := range []RouterInterface{newTestRouter(), NewRouterV2()} {
+			for _, i := range order {
+				routes[i](r)
+			}
+			for _, tt := range tests {
+				w := httptest.NewRecorder()
+				req := httptest.NewRequest(tt.method, tt.path, nil)
+				for k, v := range tt.headers {
+					req.Header.Set(k, v)
+				}
+				r.ServeHTTP(w, req)
+				assert.Equal(t, tt.code, w.Code)
+				assert.Equal(t, tt.body, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestMatchers_Registration(t *testing.T) {
+	for _, r := range []RouterInterface{newTestRouter(), NewRouterV2()} {
+		for _, register := range matcherRoutes() {
+			register(r)
+		}
+
+		// the same matchers in another order are a duplicate
+		v := func() (v any) {
+			defer func() {
+				v = recover()
+			}()
+			r.Get("/playlist", paramsHandler("again"), WithMatchers(Header("X-Client", "mobile"), Query("format", "m3u")))
+			return nil
+		}()
+		err, ok := v.(*ConflictError)
+		if !ok {
+			t.Fatalf("%T: expected a *ConflictError, got %v", r, v)
+		}
+		assert.Equal(t, "/playlist", err.Existing.Pattern)
+		assert.Equal(t, reasonDuplicate, err.Reason)
+
+		var matchers []string
+		for _, ri := range r.Routes() {
+			if ri.Pattern == "/playlist" {
+				matchers = append(matchers, strings.Join(ri.Matchers, " && "))
+			}
+		}
+		assert.Equal(t, []string{"", "header X-Client: mobile", "header X-Client: mobile && query format=m3u", "query format=m3u"}, matchers)
+
+		// unregistering a method removes every one of its routes
+		assert.Equal(t, true, r.Unregister(http.MethodGet, "/playlist"))
+		assert.Equal(t, http.StatusNotFound, serve(r, "GET", "/playlist?format=m3u").Code)
+		assert.Equal(t, 3, r.RemoveGroup("/tracks"))
+	}
+}
+
+// matchErrorHandler writes the name along with the MatchError of the request.
+func matchErrorHandler(name string, code int) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(code)
+			fmt.Fprintf(w, "%s: %v", name, MatchError(r))
+		},
+	)
+}
+
+func TestMatchers_Fallbacks(t *testing.T) {
+	rm := NewRouter(&Config{
+		LoggingLevel:                LevelOff,
+		NotFoundHandler:             matchErrorHandler("not found", 404),
+		UnsupportedMediaTypeHandler: matchErrorHandler("unsupported", 415),
+	})
+	rt := NewRouterV2(
+		NotFoundHandler(matchErrorHandler("not found", 404)),
+		UnsupportedMediaTypeHandler(matchErrorHandler("unsupported", 415)),
+	)
+	routers := []struct {
+		r  RouterInterface
+		v1 RouterGroup
+	}{
+		{rm, rm.NewGroup("v1")},
+		{rt, rt.NewGroup("v1")},
+	}
+	for _, rr := range routers {
+		r, v1 := rr.r, rr.v1
+		r.Get("/beta", paramsHandler("beta"), WithMatchers(Query("beta", "1")))
+		v1.UnsupportedMediaType(matchErrorHandler("v1 unsupported", 415))
+		v1.Post("/tracks", paramsHandler("json"), WithMatchers(ContentType("application/json")))
+
+		tests := []struct {
+			method string
+			path   string
+			code   int
+			body   string
+		}{
+			{"GET", "/beta", 404, "not found: no route matches the request: GET /beta requires query beta=1"},
+			{"GET", "/missing", 404, "not found: <nil>"},
+			{"POST", "/v1/tracks", 415, "v1 unsupported: no route matches the request: POST /v1/tracks requires content type application/json"},
+		}
+		for _, tt := range tests {
+			w := serve(r, tt.method, tt.path)
+			assert.Equal(t, tt.code, w.Code)
+			assert.Equal(t, tt.body, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package netkit
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// methodTable holds every route entry that was registered for a single
+// path pattern, keyed by method. An entry registered with the method "*"
+// answers requests of any method that has no entry of its own.
+//
+// Unless they were registered explicitly, HEAD requests are answered by
+// the GET entry with the response body discarded, and OPTIONS requests
+// are answered with the list of registered methods.
+//
+// The entries that were registered with matchers are kept apart from the
+// entry without matchers, as the variants of their method, see WithMatchers.
+type methodTable struct {
+	pattern  string
+	shape    string
+	prefix   string
+	parts    []part
+	params   []string
+	entries  map[string]routeEntry
+	variants map[string][]routeEntry // ordered by precedence
+	head     routeEntry
+	heads    []routeEntry // the implicit HEAD variants
+	options  routeEntry
+	allowed  string
+}
+
+func newMethodTable(pattern string, params []string) *methodTable {
+	return &methodTable{
+		pattern:  pattern,
+		params:   params,
+		entries:  make(map[string]routeEntry),
+		variants: make(map[string][]routeEntry),
+	}
+}
+
+// clone returns a copy of the table, which can be changed without affecting t.
+func (t *methodTable) clone() *methodTable {
+	c := *t
+	c.entries = make(map[string]routeEntry, len(t.entries))
+	for m, e := range t.entries {
+		c.entries[m] = e
+	}
+	c.variants = make(map[string][]routeEntry, len(t.variants))
+	for m, vs := range t.variants {
+		c.variants[m] = vs
+	}
+	return &c
+}
+
+// add stores the entry under its method and matchers, replacing any entry
+// that was registered for them before. It returns a boolean indicating
+// true if an existing entry was replaced.
+func (t *methodTable) add(e routeEntry) bool {
+	if len(e.matchers) == 0 {
+		_, exist := t.entries[e.method]
+		t.entries[e.method] = e
+		t.update()
+		return exist
+	}
+	key := matcherKey(e.matchers)
+	// the variants may be shared with other snapshots, so they are copied
+	vs := make([]routeEntry, 0, len(t.variants[e.method])+1)
+	exist := false
+	for _, v := range t.variants[e.method] {
+		if matcherKey(v.matchers) == key {
+			exist = true
+			continue
+		}
+		vs = append(vs, v)
+	}
+	i := 0
+	for i < len(vs) && !e.precedes(vs[i]) {
+		i++
+	}
+	vs = append(vs, routeEntry{})
+	copy(vs[i+1:], vs[i:])
+	vs[i] = e
+	t.variants[e.method] = vs
+	t.update()
+	return exist
+}
+
+// entry returns the entry registered for the method with the matchers of
+// the key, see matcherKey, along with a boolean indicating true if there
+// is one.
+func (t *methodTable) entry(method, key string) (routeEntry, bool) {
+	if key == "" {
+		e, ok := t.entries[method]
+		return e, ok
+	}
+	for _, v := range t.variants[method] {
+		if matcherKey(v.matchers) == key {
+			return v, true
+		}
+	}
+	return routeEntry{}, false
+}
+
+// remove deletes every entry registered for the method, whatever their
+// matchers, and returns them.
+func (t *methodTable) remove(method string) []routeEntry {
+	var removed []routeEntry
+	if e, ok := t.entries[method]; ok {
+		removed = append(removed, e)
+		delete(t.entries, method)
+	}
+	removed = append(removed, t.variants[method]...)
+	delete(t.variants, method)
+	if len(removed) > 0 {
+		t.update()
+	}
+	return removed
+}
+
+// has reports whether there is an entry for the method.
+func (t *methodTable) has(method string) bool {
+	_, ok := t.entries[method]
+	return ok || len(t.variants[method]) > 0
+}
+
+// empty reports whether the table has no entries left.
+func (t *methodTable) empty() bool {
+	return len(t.entries) == 0 && len(t.variants) == 0
+}
+
+// update refreshes the implicit HEAD and OPTIONS entries, along with the
+// value of the Allow header, after the registered entries have changed.
+func (t *methodTable) update() {
+	t.head = routeEntry{}
+	if get, ok := t.entries[http.MethodGet]; ok {
+		t.head = headEntry(get)
+	}
+	t.heads = nil
+	for _, get := range t.variants[http.MethodGet] {
+		t.heads = append(t.heads, headEntry(get))
+	}
+	ms := make([]string, 0, len(t.entries)+2)
+	for _, m := range t.methods() {
+		if m != "*" {
+			ms = append(ms, m)
+		}
+	}
+	if !t.has(http.MethodHead) && t.has(http.MethodGet) {
+		ms = append(ms, http.MethodHead)
+	}
+	if !t.has(http.MethodOptions) {
+		ms = append(ms, http.MethodOptions)
+	}
+	sort.Strings(ms)
+	t.allowed = strings.Join(ms, ", ")
+	t.options = routeEntry{
+		method:  http.MethodOptions,
+		pattern: t.pattern,
+		handler: handleOptions(t.allowed),
+	}
+	t.options.chained = newChained(t.options.handler)
+}
+
+// headEntry returns the implicit HEAD entry for the GET entry.
+func headEntry(get routeEntry) routeEntry {
+	get.method = http.MethodHead
+	get.handler = handleHead(get.handler)
+	get.chained = newChained(get.handler)
+	return get
+}
+
+// find returns the entry that should serve the request. It tries the entries
+// of the method of the request, then the implicit HEAD entries, then the
+// entries registered for any method, and then the implicit OPTIONS entry,
+// in that order. Within each of them, the variants are tried before the entry
+// without matchers. It returns errMethodNotAllowed if there are no entries for
+// the method, and an *unmatchedError if there are, but none of them matched.
+func (t *methodTable) find(r *http.Request) (routeEntry, error) {
+	var unmatched *unmatchedError
+	try := func(vs []routeEntry, e routeEntry, ok bool) (routeEntry, bool) {
+		for _, v := range vs {
+			matched, failed, onlyContentType := v.matches(r)
+			if matched {
+				return v, true
+			}
+			if unmatched == nil {
+				unmatched = &unmatchedError{code: http.StatusNotFound}
+			}
+			if onlyContentType {
+				unmatched.code = http.StatusUnsupportedMediaType
+			}
+			unmatched.reasons = append(unmatched.reasons, fmt.Sprintf("%s %s requires %s", v.method, v.pattern, failed))
+		}
+		return e, ok
+	}
+	e, ok := t.entries[r.Method]
+	if e, ok := try(t.variants[r.Method], e, ok); ok {
+		return e, nil
+	}
+	if r.Method == http.MethodHead {
+		if e, ok := try(t.heads, t.head, t.head.handler != nil); ok {
+			return e, nil
+		}
+	}
+	e, ok = t.entries["*"]
+	if e, ok := try(t.variants["*"], e, ok); ok {
+		return e, nil
+	}
+	if r.Method == http.MethodOptions {
+		return t.options, nil
+	}
+	if unmatched != nil {
+		return routeEntry{}, unmatched
+	}
+	return routeEntry{}, errMethodNotAllowed
+}
+
+// methods returns the registered methods in sorted order.
+func (t *methodTable) methods() []string {
+	ms := make([]string, 0, len(t.entries)+len(t.variants))
+	for m := range t.entries {
+		ms = append(ms, m)
+	}
+	for m := range t.variants {
+		if _, ok := t.entries[m]; !ok {
+			ms = append(ms, m)
+		}
+	}
+	sort.Strings(ms)
+	return ms
+}
+
+// sorted returns the registered entries, sorted by method. The variants of
+// a method come before its entry without matchers, in order of precedence.
+func (t *methodTable) sorted() []routeEntry {
+	es := make([]routeEntry, 0, len(t.entries)+len(t.variants))
+	for _, m := range t.methods() {
+		es = append(es, t.variants[m]...)
+		if e, ok := t.entries[m]; ok {
+			es = append(es, e)
+		}
+	}
+	return es
+}
+
+// allow returns the value of the Allow header for the path, which lists
+// the registered methods along with the implicit HEAD and OPTIONS.
+func (t *methodTable) allow() string {
+	return t.allowed
+}
+
+// deleteTable returns a copy of the list without the table, keeping the
+// order of the remaining tables. The list may be shared with another
+// snapshot, so it is never changed in place.
+func deleteTable(ts []*methodTable, t *methodTable) []*methodTable {
+	for i := range ts {
+		if ts[i] == t {
+			return append(append(make([]*methodTable, 0, len(ts)-1), ts[:i]...), ts[i+1:]...)
+		}
+	}
+	return ts
+}
+
+// replaceTable returns a copy of the list in which the table old is replaced
+// by t, or the list itself if old is not in it.
+func replaceTable(ts []*methodTable, old, t *methodTable) []*methodTable {
+	for i := range ts {
+		if ts[i] == old {
+			c := append([]*methodTable(nil), ts...)
+			c[i] = t
+			return c
+		}
+	}
+	return ts
+}
+
+// countRoutes returns the number of routes that the entries belong to,
+// counting the variants of a pattern with optional parameters once, and
+// leaving out the implicit entries that the router registered itself, such
+// as the roots of groups.
+func countRoutes(es []routeEntry) int {
+	n := 0
+	for _, e := range es {
+		if e.variant == 0 && !e.implicit {
+			n++
+		}
+	}
+	return n
+}
+
+// inGroup reports whether the pattern was registered below the prefix of
+// a group, which has no trailing slash.
+func inGroup(prefix, pattern string) bool {
+	return strings.HasPrefix(pattern, prefix) && (len(pattern) == len(prefix) || pattern[len(prefix)] == '/')
+}
+
+// headResponseWriter discards everything that is written to the body of
+// the response, so a GET handler can be used to answer HEAD requests.
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// handleHead returns a handler that serves HEAD requests with the provided
+// GET handler, and discards the response body.
+func handleHead(get http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			get.ServeHTTP(headResponseWriter{w}, r)
+		},
+	)
+}
+
+// handleOptions returns a handler that answers OPTIONS requests with an
+// Allow header listing the methods that are registered.
+func handleOptions(allow string) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(HeaderAllow, allow)
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+// handleMethodNotAllowed returns a handler that responds with a 405 status
+// code, and an Allow header listing the methods that are registered.
+func handleMethodNotAllowed(allow string) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(HeaderAllow, allow)
+			code := http.StatusMethodNotAllowed
+			http.Error(w, http.StatusText(code), code)
+		},
+	)
+}
@@ -12,6 +12,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -153,30 +154,46 @@ func HandleErrors(w http.ResponseWriter, r *http.Request) {
 // passes through the http.Handler you provide.
 func HandleWithLogging(logger *Logger, next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				logger.Error("err: %v, trace: %s\n", err, debug.Stack())
+		serveWithLogging(logger, next, w, r, false)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// serveWithLogging serves the request with next, and logs the outcome. The
+// writer that records the status and size of the response is taken from a
+// pool, so that Router can log requests without wrapping its handlers.
+//
+// A panic is logged and answered with a 500. If repanic is set, because a
+// panic handler of the router or group will recover it, the panic is logged
+// and then panics again instead. An http.ErrAbortHandler is not logged and
+// always panics again, so that net/http aborts the response.
+func serveWithLogging(logger *Logger, next http.Handler, w http.ResponseWriter, r *http.Request, repanic bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			if err == http.ErrAbortHandler {
+				panic(err)
 			}
-		}()
-		lrw := loggingResponseWriter{
-			ResponseWriter: w,
-			data: &responseData{
-				status: 200,
-				size:   0,
-			},
-		}
-		next.ServeHTTP(&lrw, r)
-		if 400 <= lrw.data.status && lrw.data.status <= 599 {
-			str, args := logStr(lrw.data.status, r)
-			logger.Error(str, args...)
-			return
+			logger.Error("err: %v, trace: %s\n", err, debug.Stack())
+			if repanic {
+				panic(err)
+			}
+			w.WriteHeader(http.StatusInternalServerError)
 		}
-		str, args := logStr(lrw.data.status, r)
-		logger.Info(str, args...)
+	}()
+	lrw := loggingWriterPool.Get().(*loggingResponseWriter)
+	lrw.ResponseWriter = w
+	lrw.data = responseData{status: 200}
+	next.ServeHTTP(lrw, r)
+	status := lrw.data.status
+	lrw.ResponseWriter = nil
+	loggingWriterPool.Put(lrw)
+	if 400 <= status && status <= 599 {
+		str, args := logStr(status, r)
+		logger.Error(str, args...)
 		return
 	}
-	return http.HandlerFunc(fn)
+	str, args := logStr(status, r)
+	logger.Info(str, args...)
 }
 
 type responseData struct {
@@ -188,7 +205,11 @@ type responseData struct {
 // with Header(), Write(b []byte) (int, error) and WriteHeader(int)
 type loggingResponseWriter struct {
 	http.ResponseWriter
-	data *responseData
+	data responseData
+}
+
+var loggingWriterPool = sync.Pool{
+	New: func() any { return new(loggingResponseWriter) },
 }
 
 func (w *loggingResponseWriter) Header() http.Header {
@@ -0,0 +1,118 @@
+package netkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Jonny-Burkholder/streaming-example/pkg/assert"
+)
+
+// trace returns middleware that appends its name to the X-Trace header
+// of the response before calling the next handler.
+func trace(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Trace", name)
+				next.ServeHTTP(w, r)
+			},
+		)
+	}
+}
+
+func TestRouter_Use(t *testing.T) {
+	rm := newTestRouter()
+	rm.Use(trace("router"))
+	v1 := rm.NewGroup("v1")
+	v1.Use(trace("group"))
+	v1.Get("/audio/:id", paramsHandler("track", "id"), WithMiddleware(trace("route"), trace("route2")))
+	rm.Get("/ping", paramsHandler("ping"))
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+		trace  []string
+	}{
+		{"GET", "/v1/audio/42", 200, []string{"router", "group", "route", "route2"}},
+		{"GET", "/ping", 200, []string{"router"}},
+		{"GET", "/missing", 404, []string{"router"}},
+		{"POST", "/ping", 405, []string{"router"}},
+	}
+	for _, tt := range tests {
+		w := serve(rm, tt.method, tt.path)
+		assert.Equal(t, tt.code, w.Code)
+		assert.Equal(t, tt.trace, w.Header().Values("X-Trace"))
+	}
+}
+
+func TestRouterV2_Use(t *testing.T) {
+	rt := NewRouterV2()
+	rt.Use(trace("router"))
+	v2 := rt.NewGroup("v2")
+	v2.Get("/before", paramsHandler("before"))
+	v2.Use(trace("group"))
+	v2.Get("/audio/:id", paramsHandler("track", "id"), WithMiddleware(trace("route")))
+
+	w := serve(rt, "GET", "/v2/audio/42")
+	assert.Equal(t, "track id=42", w.Body.String())
+	assert.Equal(t, []string{"router", "group", "route"}, w.Header().Values("X-Trace"))
+
+	// group middleware also applies to the routes registered before Use
+	w = serve(rt, "GET", "/v2/before")
+	assert.Equal(t, []string{"router", "group"}, w.Header().Values("X-Trace"))
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	rm := newTestRouter()
+	rm.Use(CORSMiddleware(nil))
+	rm.Get("/ping", paramsHandler("ping"))
+
+	w := serve(rm, "GET", "/ping")
+	assert.Equal(t, "ping", w.Body.String())
+	assert.Equal(t, "*", w.Header().Get(HeaderAccessControlAllowOrigin))
+
+	// a pre-flight request is answered by the middleware
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("OPTIONS", "/ping", nil)
+	r.Header.Set(HeaderAccessControlRequestMethod, "POST")
+	rm.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "", w.Header().Get(HeaderAllow))
+
+	// a plain OPTIONS request is answered by the router
+	w = serve(rm, "OPTIONS", "/ping")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "GET, HEAD, OPTIONS", w.Header().Get(HeaderAllow))
+}
+
+func TestUse_AfterServing(t *testing.T) {
+	rm := newTestRouter()
+	v1 := rm.NewGroup("v1")
+	v1.Get("/audio/:id", paramsHandler("track", "id"))
+	rt := NewRouterV2()
+	v2 := rt.NewGroup("v1")
+	v2.Get("/audio/:id", paramsHandler("track", "id"))
+
+	// the middleware is built around the handlers when they are served, and
+	// built again once middleware is added
+	for _, r := range []struct {
+		rt    http.Handler
+		use   func(...Middleware)
+		group func(...Middleware)
+	}{
+		{rm, rm.Use, v1.Use},
+		{rt, rt.Use, v2.Use},
+	} {
+		assert.Equal(t, 0, len(serve(r.rt, "GET", "/v1/audio/42").Header().Values("X-Trace")))
+		r.use(trace("router"))
+		assert.Equal(t, []string{"router"}, serve(r.rt, "GET", "/v1/audio/42").Header().Values("X-Trace"))
+		r.group(trace("group"))
+		w := serve(r.rt, "GET", "/v1/audio/42")
+		assert.Equal(t, "track id=42", w.Body.String())
+		assert.Equal(t, []string{"router", "group"}, w.Header().Values("X-Trace"))
+		r.use(trace("router2"))
+		assert.Equal(t, []string{"router", "router2", "group"}, serve(r.rt, "GET", "/v1/audio/42").Header().Values("X-Trace"))
+	}
+}
@@ -0,0 +1,80 @@
+package netkit
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// mountPrefix standardizes the prefix that a handler is mounted at, so
+// that it starts with a slash and does not end with one. The root prefix
+// is returned as an empty string. It panics if the prefix is not static.
+func mountPrefix(prefix string) string {
+	if len(prefix) == 0 || prefix[0] != '/' {
+		prefix = "/" + prefix
+	}
+	pat, err := CompilePattern(prefix)
+	if err != nil {
+		panic(err)
+	}
+	if !pat.isStatic() {
+		panic(fmt.Sprintf("netkit: invalid mount prefix %q: prefix must not contain parameters", prefix))
+	}
+	return strings.TrimRight(prefix, "/")
+}
+
+// withoutParams keeps the catch-all that a handler is mounted under out of
+// the parameters of the request, since it is only there to match the
+// subtree.
+func withoutParams() RouteOption {
+	return func(e *routeEntry) {
+		e.params = nil
+	}
+}
+
+// stripPrefix works like http.StripPrefix, except that the path it hands
+// to h always starts with a slash, so a mounted router sees "/" when the
+// prefix itself is requested.
+func stripPrefix(prefix string, h http.Handler) http.Handler {
+	if prefix == "" {
+		return h
+	}
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			p, rp, ok := trimPath(r.URL, prefix)
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.URL = new(url.URL)
+			*r2.URL = *r.URL
+			r2.URL.Path = withSlash(p)
+			if rp != "" {
+				r2.URL.RawPath = withSlash(rp)
+			}
+			h.ServeHTTP(w, r2)
+		},
+	)
+}
+
+// trimPath returns the path and the raw path of u without the prefix, and
+// reports whether both of them started with it.
+func trimPath(u *url.URL, prefix string) (string, string, bool) {
+	p := strings.TrimPrefix(u.Path, prefix)
+	rp := strings.TrimPrefix(u.RawPath, prefix)
+	if len(p) == len(u.Path) || (u.RawPath != "" && len(rp) == len(u.RawPath)) {
+		return "", "", false
+	}
+	return p, rp, true
+}
+
+// withSlash returns p with a leading slash.
+func withSlash(p string) string {
+	if len(p) == 0 || p[0] != '/' {
+		return "/" + p
+	}
+	return p
+}
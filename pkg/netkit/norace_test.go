@@ -0,0 +1,5 @@
+//go:build !race
+
+package netkit
+
+const raceEnabled = false
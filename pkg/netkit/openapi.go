@@ -0,0 +1,407 @@
+package netkit
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Jonny-Burkholder/streaming-example/pkg/trees/radix"
+)
+
+// RouteDoc documents a route. It is filled in by route options such as
+// WithSummary and WithResponse, and is used to generate OpenAPI documents.
+type RouteDoc struct {
+	Summary   string
+	Tags      []string
+	Request   reflect.Type         // the type of the request body, if it has one
+	Responses map[int]reflect.Type // the type of the response body by status code, nil for no body
+	Params    map[string]string    // the descriptions of the parameters by name
+}
+
+// clone returns a copy of the doc, which shares nothing with d.
+func (d *RouteDoc) clone() *RouteDoc {
+	if d == nil {
+		return nil
+	}
+	c := *d
+	c.Tags = append([]string(nil), d.Tags...)
+	c.Responses = make(map[int]reflect.Type, len(d.Responses))
+	for code, t := range d.Responses {
+		c.Responses[code] = t
+	}
+	c.Params = make(map[string]string, len(d.Params))
+	for name, desc := range d.Params {
+		c.Params[name] = desc
+	}
+	return &c
+}
+
+// routeDoc returns the doc of the entry, and creates it if there is none.
+func (m *routeEntry) routeDoc() *RouteDoc {
+	if m.doc == nil {
+		m.doc = &RouteDoc{
+			Responses: make(map[int]reflect.Type),
+			Params:    make(map[string]string),
+		}
+	}
+	return m.doc
+}
+
+// WithSummary sets the short summary of what the route does.
+func WithSummary(summary string) RouteOption {
+	return func(e *routeEntry) {
+		e.routeDoc().Summary = summary
+	}
+}
+
+// WithTags adds tags to the route, which are used to group the operations
+// of an OpenAPI document.
+func WithTags(tags ...string) RouteOption {
+	return func(e *routeEntry) {
+		doc := e.routeDoc()
+		doc.Tags = append(doc.Tags, tags...)
+	}
+}
+
+// WithRequest documents the request body of the route as JSON matching the
+// type of v, which is usually the zero value of a struct.
+func WithRequest(v any) RouteOption {
+	return func(e *routeEntry) {
+		e.routeDoc().Request = reflect.TypeOf(v)
+	}
+}
+
+// WithResponse documents a response of the route with the status code, whose
+// body is JSON matching the type of v. A nil v documents a response without
+// a body.
+func WithResponse(code int, v any) RouteOption {
+	return func(e *routeEntry) {
+		e.routeDoc().Responses[code] = reflect.TypeOf(v)
+	}
+}
+
+// WithParamDescription documents the parameter of the route with the name.
+func WithParamDescription(name, description string) RouteOption {
+	return func(e *routeEntry) {
+		e.routeDoc().Params[name] = description
+	}
+}
+
+// OpenAPIInfo is the info object of an OpenAPI document.
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenAPIDocument is an OpenAPI 3 document, which is generated by NewOpenAPI.
+// Only the parts of the specification that netkit fills in are modeled.
+type OpenAPIDocument struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       OpenAPIInfo                      `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components,omitempty"`
+}
+
+// Components holds the schemas that are referred to by the document.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// Operation is a single method on a path.
+type Operation struct {
+	OperationID string               `json:"operationId,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter is a path parameter of an operation.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is the request body of an operation.
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is a response of an operation.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is a JSON schema, as used by OpenAPI.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+// NewOpenAPI generates an OpenAPI 3 document from the routes, as returned by
+// the Routes method of a router. Routes that answer any method, such as those
+// registered by Mount, and routes that belong to a host are left out, since
+// they can not be described by an OpenAPI path. The schemas of the request and
+// response bodies are generated from their Go types, following their json
+// struct tags, and named struct types are put in the components of the document.
+func NewOpenAPI(info OpenAPIInfo, routes []RouteInfo) *OpenAPIDocument {
+	doc := &OpenAPIDocument{
+		OpenAPI:    "3.0.3",
+		Info:       info,
+		Paths:      make(map[string]map[string]*Operation),
+		Components: Components{Schemas: make(map[string]*Schema)},
+	}
+	for _, ri := range routes {
+		if ri.Method == "*" || ri.Host != "" {
+			continue
+		}
+		pat, err := CompilePattern(ri.Pattern)
+		if err != nil {
+			continue
+		}
+		path, params := openAPIPath(pat)
+		op := &Operation{
+			OperationID: ri.Name,
+			Responses:   make(map[string]*Response),
+		}
+		d := ri.Doc
+		if d == nil {
+			d = &RouteDoc{}
+		}
+		op.Summary = d.Summary
+		op.Tags = d.Tags
+		for _, p := range params {
+			p.Description = d.Params[p.Name]
+			op.Parameters = append(op.Parameters, p)
+		}
+		if d.Request != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  jsonContent(doc.schema(d.Request)),
+			}
+		}
+		for code, t := range d.Responses {
+			resp := &Response{Description: http.StatusText(code)}
+			if t != nil {
+				resp.Content = jsonContent(doc.schema(t))
+			}
+			op.Responses[strconv.Itoa(code)] = resp
+		}
+		if len(op.Responses) == 0 {
+			op.Responses["200"] = &Response{Description: http.StatusText(http.StatusOK)}
+		}
+		doc.add(path, ri.Method, op)
+		// OpenAPI has no optional path parameters, so each variant of the
+		// pattern gets an operation of its own
+		for _, v := range pat.shorter {
+			path, params := openAPIPath(v)
+			vop := *op
+			vop.OperationID = ""
+			vop.Parameters = op.Parameters[:len(params):len(params)]
+			doc.add(path, ri.Method, &vop)
+		}
+	}
+	return doc
+}
+
+// add adds the operation for the method to the path.
+func (doc *OpenAPIDocument) add(path, method string, op *Operation) {
+	if doc.Paths[path] == nil {
+		doc.Paths[path] = make(map[string]*Operation)
+	}
+	doc.Paths[path][strings.ToLower(method)] = op
+}
+
+// OpenAPIHandler returns a handler that serves the OpenAPI document of the
+// routes of the router as JSON. The document is generated for each request,
+// so it always matches the routes that are registered.
+func OpenAPIHandler(rl RouteLister, info OpenAPIInfo) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", mime.TypeByExtension(".json"))
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			enc.Encode(NewOpenAPI(info, rl.Routes()))
+		},
+	)
+}
+
+// ServeOpenAPI serves the OpenAPI document of the router at the path, see
+// OpenAPIHandler. Router does the same when OpenAPIPath is set in its Config.
+func ServeOpenAPI(path string, info OpenAPIInfo) RouterV2Option {
+	return func(rt *RouterV2) {
+		rt.Handle(http.MethodGet, path, OpenAPIHandler(rt, info))
+	}
+}
+
+// openAPIPath returns the path of the pattern in the form OpenAPI uses, in
+// which every parameter is written as "{name}", along with its parameters.
+// A constraint becomes the pattern of the schema of its parameter.
+func openAPIPath(pat *Pattern) (string, []Parameter) {
+	var sb strings.Builder
+	var params []Parameter
+	var i, k int
+	for _, seg := range pat.segs {
+		switch seg.Kind {
+		case radix.Static:
+			sb.WriteString(seg.Text)
+		case radix.Mixed:
+			for _, pc := range pat.mixed[k] {
+				if !pc.param {
+					sb.WriteString(pc.text)
+					continue
+				}
+				sb.WriteString("{" + pc.text + "}")
+				params = append(params, pathParameter(pc.text, pc.kind, pc.expr))
+				i++
+			}
+			k++
+		default:
+			name := pat.names[i]
+			i++
+			sb.WriteString("{" + name + "}")
+			params = append(params, pathParameter(name, seg.Kind, seg.Text))
+		}
+	}
+	return sb.String(), params
+}
+
+// pathParameter returns the OpenAPI parameter for a path parameter of the
+// kind with the constraint expr.
+func pathParameter(name string, kind radix.Kind, expr string) Parameter {
+	schema := &Schema{Type: "string"}
+	switch {
+	case kind != radix.Param || expr == "":
+	case expr == "int":
+		schema = &Schema{Type: "integer", Format: "int64"}
+	case expr == "uuid" || expr == "date":
+		schema.Format = expr
+	default:
+		// the non-capturing group keeps an alternation such as
+		// "audio|video" anchored at both ends, just like the router
+		// matches it
+		schema.Pattern = "^(?:" + expr + ")$"
+	}
+	return Parameter{Name: name, In: "path", Required: true, Schema: schema}
+}
+
+func jsonContent(s *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: s}}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema returns the schema of the Go type. Named struct types are added
+// to the components of the document, and referred to by name.
+func (doc *OpenAPIDocument) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		name := t.Name()
+		if _, found := doc.Components.Schemas[name]; !found {
+			// register the name first, so that recursive types end
+			doc.Components.Schemas[name] = nil
+			doc.Components.Schemas[name] = doc.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		zero := 0.0
+		return &Schema{Type: "integer", Minimum: &zero}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: doc.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: doc.schema(t.Elem())}
+	case reflect.Struct:
+		return doc.structSchema(t)
+	}
+	// interfaces and everything else can hold any value
+	return &Schema{}
+}
+
+// structSchema returns the schema of the struct type, whose properties are
+// named by the json tags of its fields, just like encoding/json does. The
+// fields without omitempty are required.
+func (doc *OpenAPIDocument) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			// the fields of an embedded struct are promoted
+			embedded := doc.structSchema(ft)
+			for n, p := range embedded.Properties {
+				s.Properties[n] = p
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = doc.schema(f.Type)
+		if !strings.Contains(","+opts+",", ",omitempty,") && f.Type.Kind() != reflect.Pointer {
+			s.Required = append(s.Required, name)
+		}
+	}
+	sort.Strings(s.Required)
+	return s
+}
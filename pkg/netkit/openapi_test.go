@@ -0,0 +1,139 @@
+package netkit
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Jonny-Burkholder/streaming-example/pkg/assert"
+)
+
+type docBase struct {
+	ID int64 `json:"id"`
+}
+
+type docTrack struct {
+	docBase
+	Title   string    `json:"title"`
+	Tags    []string  `json:"tags,omitempty"`
+	Album   *docAlbum `json:"album"`
+	Cover   []byte    `json:"cover,omitempty"`
+	Added   time.Time `json:"added"`
+	Plays   uint      `json:"plays"`
+	Secret  string    `json:"-"`
+	private string
+}
+
+type docAlbum struct {
+	Name   string              `json:"name"`
+	Tracks []docTrack          `json:"tracks,omitempty"`
+	Extra  map[string]any      `json:"extra,omitempty"`
+	Rating map[string]float32  `json:"rating,omitempty"`
+	Links  map[string][]string `json:"links,omitempty"`
+}
+
+func TestNewOpenAPI(t *testing.T) {
+	rt := NewRouterV2()
+	v1 := rt.NewGroup("v1")
+	v1.Get("/audio/{id:[0-9]+}", paramsHandler("track"),
+		WithName("audio.track"),
+		WithSummary("Get a track"),
+		WithTags("audio"),
+		WithParamDescription("id", "the id of the track"),
+		WithResponse(http.StatusOK, docTrack{}),
+		WithResponse(http.StatusNotFound, nil),
+	)
+	v1.Post("/audio", paramsHandler("upload"),
+		WithRequest(&docTrack{}),
+		WithResponse(http.StatusCreated, []docTrack{}),
+	)
+	rt.Get("/files/*path", paramsHandler("files"))
+	rt.Get("/media/{kind:audio|video}", paramsHandler("media"))
+	rt.Mount("/static", http.NotFoundHandler())
+	rt.Host("media.example.com").Get("/admin", paramsHandler("admin"))
+
+	doc := NewOpenAPI(OpenAPIInfo{Title: "stream", Version: "1.0"}, rt.Routes())
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+	assert.Equal(t, OpenAPIInfo{Title: "stream", Version: "1.0"}, doc.Info)
+
+	// routes for any method and host routes are left out
+	paths := make([]string, 0, len(doc.Paths))
+	for p := range doc.Paths {
+		paths = append(paths, p)
+	}
+	assert.Equal(t, 4, len(paths))
+
+	get := doc.Paths["/v1/audio/{id}"]["get"]
+	assert.Equal(t, "audio.track", get.OperationID)
+	assert.Equal(t, "Get a track", get.Summary)
+	assert.Equal(t, []string{"audio"}, get.Tags)
+	assert.Equal(t, []Parameter{{
+		Name:        "id",
+		In:          "path",
+		Description: "the id of the track",
+		Required:    true,
+		Schema:      &Schema{Type: "string", Pattern: "^(?:[0-9]+)$"},
+	}}, get.Parameters)
+	assert.Equal(t, (*RequestBody)(nil), get.RequestBody)
+	assert.Equal(t, &Response{Description: "Not Found"}, get.Responses["404"])
+	assert.Equal(t, jsonContent(&Schema{Ref: "#/components/schemas/docTrack"}), get.Responses["200"].Content)
+
+	post := doc.Paths["/v1/audio"]["post"]
+	assert.Equal(t, jsonContent(&Schema{Ref: "#/components/schemas/docTrack"}), post.RequestBody.Content)
+	assert.Equal(t, jsonContent(&Schema{Type: "array", Items: &Schema{Ref: "#/components/schemas/docTrack"}}), post.Responses["201"].Content)
+
+	files := doc.Paths["/files/{path}"]["get"]
+	assert.Equal(t, map[string]*Response{"200": {Description: "OK"}}, files.Responses)
+	assert.Equal(t, "path", files.Parameters[0].Name)
+
+	// an alternation is anchored as a whole
+	media := doc.Paths["/media/{kind}"]["get"]
+	assert.Equal(t, &Schema{Type: "string", Pattern: "^(?:audio|video)$"}, media.Parameters[0].Schema)
+
+	zero := 0.0
+	assert.Equal(t, &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"id":    {Type: "integer", Format: "int64"},
+			"title": {Type: "string"},
+			"tags":  {Type: "array", Items: &Schema{Type: "string"}},
+			"album": {Ref: "#/components/schemas/docAlbum"},
+			"cover": {Type: "string", Format: "byte"},
+			"added": {Type: "string", Format: "date-time"},
+			"plays": {Type: "integer", Minimum: &zero},
+		},
+		Required: []string{"added", "id", "plays", "title"},
+	}, doc.Components.Schemas["docTrack"])
+	assert.Equal(t, &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"name":   {Type: "string"},
+			"tracks": {Type: "array", Items: &Schema{Ref: "#/components/schemas/docTrack"}},
+			"extra":  {Type: "object", AdditionalProperties: &Schema{}},
+			"rating": {Type: "object", AdditionalProperties: &Schema{Type: "number", Format: "float"}},
+			"links":  {Type: "object", AdditionalProperties: &Schema{Type: "array", Items: &Schema{Type: "string"}}},
+		},
+		Required: []string{"name"},
+	}, doc.Components.Schemas["docAlbum"])
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	info := OpenAPIInfo{Title: "stream", Version: "1.0"}
+	rm := NewRouter(&Config{LoggingLevel: LevelOff, OpenAPIPath: "/openapi.json", OpenAPIInfo: info})
+	rm.Get("/ping", paramsHandler("ping"), WithSummary("Ping"))
+	rt := NewRouterV2(ServeOpenAPI("/openapi.json", info))
+	rt.Get("/ping", paramsHandler("ping"), WithSummary("Ping"))
+
+	for _, r := range []http.Handler{rm, rt} {
+		w := serve(r, "GET", "/openapi.json")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		var doc OpenAPIDocument
+		err := json.Unmarshal(w.Body.Bytes(), &doc)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, info, doc.Info)
+		assert.Equal(t, "Ping", doc.Paths["/ping"]["get"].Summary)
+		assert.Equal(t, true, doc.Paths["/openapi.json"]["get"] != nil)
+	}
+}
@@ -0,0 +1,120 @@
+package netkit
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// RouteOption configures a single route when it is registered, and can be
+// passed to Handle, HandleFunc and each of the method helpers.
+type RouteOption func(*routeEntry)
+
+// WithName names the route, so that its URL can be built later on by
+// passing the name to the URL method of the router.
+func WithName(name string) RouteOption {
+	return func(e *routeEntry) {
+		e.name = name
+	}
+}
+
+// WithMiddleware wraps the handler of the route in the middleware. Route
+// middleware runs after the middleware of the router and of the group,
+// and the middleware is called in the order it is provided.
+func WithMiddleware(mw ...Middleware) RouteOption {
+	return func(e *routeEntry) {
+		e.middleware = append(e.middleware, mw...)
+	}
+}
+
+// applyOptions applies the route options to the entry, and wraps the
+// handler of the entry in its middleware.
+func applyOptions(e *routeEntry, opts []RouteOption) {
+	for _, opt := range opts {
+		if opt != nil {
+			opt(e)
+		}
+	}
+	if len(e.middleware) > 0 {
+		e.handler = NewChain(e.middleware...).Then(e.handler)
+	}
+	if e.groupChain != nil {
+		e.handler = &groupHandler{chain: e.groupChain, next: newChained(e.handler)}
+	}
+	e.chained = newChained(e.handler)
+}
+
+// groupOptions records the group of a route that is registered on the
+// group, along with the middleware of the group, in front of the options
+// of the route.
+func groupOptions(group string, gc *groupChain, opts []RouteOption) []RouteOption {
+	group = strings.TrimSuffix(group, "/")
+	setGroup := func(e *routeEntry) {
+		e.group = group
+		e.groupChain = gc
+	}
+	return append([]RouteOption{setGroup}, opts...)
+}
+
+// groupChain holds the middleware of a group. It is looked up for each
+// request, so the middleware that is added by Use also runs for the routes
+// and the nested groups that were created before it, just like the
+// middleware of the router.
+type groupChain struct {
+	parent *groupChain
+	own    *Chain        // the middleware added to the group itself
+	subs   []*groupChain // the nested groups
+	chain  atomic.Pointer[Chain]
+}
+
+// newGroupChain returns the chain of a group nested inside parent, which
+// may be nil. It must be called with the lock of the router held.
+func newGroupChain(parent *groupChain) *groupChain {
+	gc := &groupChain{parent: parent, own: NewChain()}
+	if parent != nil {
+		parent.subs = append(parent.subs, gc)
+	}
+	gc.refresh()
+	return gc
+}
+
+// use appends the middleware to the group. It must be called with the lock
+// of the router held.
+func (gc *groupChain) use(mw ...Middleware) {
+	gc.own = gc.own.Append(mw...)
+	gc.refresh()
+}
+
+// refresh stores the middleware of the parent groups followed by the own
+// middleware of the group, and does the same for the nested groups.
+func (gc *groupChain) refresh() {
+	c := gc.own
+	if gc.parent != nil {
+		c = gc.parent.chain.Load().Extend(gc.own)
+	}
+	gc.chain.Store(c)
+	for _, sub := range gc.subs {
+		sub.refresh()
+	}
+}
+
+// middleware returns the middleware of the group, which is empty for a
+// route outside of any group.
+func (gc *groupChain) middleware() []Middleware {
+	if gc == nil {
+		return nil
+	}
+	return gc.chain.Load().mw
+}
+
+// groupHandler runs the current middleware of a group in front of the
+// handler of a route. The middleware is built around the handler once for
+// each change to the middleware of the group, see chained.
+type groupHandler struct {
+	chain *groupChain
+	next  *chained
+}
+
+func (h *groupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.next.then(h.chain.chain.Load()).ServeHTTP(w, r)
+}
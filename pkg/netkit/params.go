@@ -0,0 +1,157 @@
+package netkit
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// contextKey is a value for use with context.WithValue. It's used as
+// a pointer so it fits in an interface{} without allocation.
+type contextKey struct {
+	name string
+}
+
+func (k *contextKey) String() string {
+	return "netkit context value " + k.name
+}
+
+// paramsContextKey is the context key that holds the Params captured
+// by the router for the current request.
+var paramsContextKey = &contextKey{"params"}
+
+// PathParam is a single path parameter, consisting of a key and a value.
+type PathParam struct {
+	Key   string
+	Value string
+}
+
+// Params holds the path parameters that were captured while matching a
+// route, in the order they appear in the pattern.
+//
+// The parameters are only valid until the handler of the route returns.
+// The router reuses their storage for the next request, along with the
+// request that it passed to the handler, and the context of that request,
+// which carries the parameters. A handler that keeps the parameters around,
+// in a goroutine for example, must keep a Clone, and must not keep the
+// request or its context at all.
+type Params []PathParam
+
+// Clone returns a copy of the parameters that does not share their storage.
+func (ps Params) Clone() Params {
+	if ps == nil {
+		return nil
+	}
+	return append(Params(nil), ps...)
+}
+
+// Get returns the value of the first parameter with the given key. It
+// returns an empty string if no parameter with that key exists.
+func (ps Params) Get(key string) string {
+	v, _ := ps.Lookup(key)
+	return v
+}
+
+// Lookup returns the value of the first parameter with the given key,
+// along with a boolean indicating true if the parameter was found.
+func (ps Params) Lookup(key string) (string, bool) {
+	for i := range ps {
+		if ps[i].Key == key {
+			return ps[i].Value, true
+		}
+	}
+	return "", false
+}
+
+// WithParams returns a copy of ctx that carries the provided Params.
+func WithParams(ctx context.Context, ps Params) context.Context {
+	return context.WithValue(ctx, paramsContextKey, &ps)
+}
+
+// ParamsFromContext returns the Params stored in ctx, if there are any.
+func ParamsFromContext(ctx context.Context) Params {
+	if ps, ok := ctx.Value(paramsContextKey).(*Params); ok {
+		return *ps
+	}
+	return nil
+}
+
+// Param returns the value of the named path parameter that the router
+// captured for the request. It returns an empty string if the matched
+// route does not have a parameter with that name.
+func Param(r *http.Request, name string) string {
+	return ParamsFromContext(r.Context()).Get(name)
+}
+
+// routeParams is the storage for the parameters of a request. It is taken
+// from paramsPool while the request is routed and served, so that routing
+// does not allocate, and put back once the handler returns, see Params. It
+// doubles as the context that carries the parameters, and as the shallow
+// copy of the request that carries the context.
+type routeParams struct {
+	context.Context
+	ps   Params
+	vals []string // the values captured while matching the path
+	req  http.Request
+}
+
+var paramsPool = sync.Pool{
+	New: func() any {
+		return &routeParams{
+			ps:   make(Params, 0, 8),
+			vals: make([]string, 0, 8),
+		}
+	},
+}
+
+// getParams returns storage for the parameters of a request from the pool.
+func getParams() *routeParams {
+	return paramsPool.Get().(*routeParams)
+}
+
+// put returns the storage to the pool, once the request has been served.
+// Neither the request nor the parameters may be used afterwards.
+func (p *routeParams) put() {
+	for i := range p.ps {
+		p.ps[i] = PathParam{}
+	}
+	for i := range p.vals {
+		p.vals[i] = ""
+	}
+	p.ps, p.vals = p.ps[:0], p.vals[:0]
+	p.Context = nil
+	p.req = http.Request{}
+	paramsPool.Put(p)
+}
+
+// Value returns the parameters for paramsContextKey, and otherwise the value
+// of the context of the request that is being served.
+func (p *routeParams) Value(key any) any {
+	if key == paramsContextKey {
+		return &p.ps
+	}
+	return p.Context.Value(key)
+}
+
+// request pairs up the parameter names of a route with the values that
+// were captured while matching it, and returns a shallow copy of the request
+// that carries them, after any parameters that were already captured (by a
+// host pattern, for example). Names without a value, which belong to absent
+// optional parameters, are given an empty value. The copy is backed by p,
+// and the request is returned unchanged when there is nothing to add.
+func (p *routeParams) request(r *http.Request, names []string, vals []string) *http.Request {
+	if len(names) == 0 {
+		return r
+	}
+	p.ps = append(p.ps[:0], ParamsFromContext(r.Context())...)
+	for i := range names {
+		var v string
+		if i < len(vals) {
+			v = vals[i]
+		}
+		p.ps = append(p.ps, PathParam{Key: names[i], Value: v})
+	}
+	p.Context = r.Context()
+	p.req = *r.WithContext(p)
+	return &p.req
+}
@@ -0,0 +1,202 @@
+package netkit
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// paramTypes are the types that a parameter can be given in place of a
+// regular expression, as in "{id:int}". Their values are checked while
+// matching, without a regular expression, and can be read by the handler
+// with ParamInt, ParamUUID and ParamDate.
+var paramTypes = map[string]func(string) bool{
+	"int":  isInt,
+	"uuid": isUUID,
+	"date": isDate,
+}
+
+// compileConstraint returns the function that checks the values of a
+// parameter against its constraint, which is either one of the paramTypes
+// or a regular expression that has to match the whole value.
+func compileConstraint(expr string) (func(string) bool, error) {
+	if match, ok := paramTypes[expr]; ok {
+		return match, nil
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, err
+	}
+	return re.MatchString, nil
+}
+
+// isInt reports whether s is a decimal integer, with an optional sign,
+// that fits in an int64.
+func isInt(s string) bool {
+	digits := s
+	if len(digits) > 0 && digits[0] == '-' {
+		digits = digits[1:]
+	}
+	if digits == "" {
+		return false
+	}
+	for i := 0; i < len(digits); i++ {
+		if !isDigit(digits[i]) {
+			return false
+		}
+	}
+	if len(digits) < 19 {
+		return true
+	}
+	_, err := strconv.ParseInt(s, 10, 64)
+	return err == nil
+}
+
+// isUUID reports whether s is a UUID in its canonical form, as in
+// "f47ac10b-58cc-4372-a567-0e02b2c3d479", in either case.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			if !isHex(s[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isDate reports whether s is a calendar date in the form "2006-01-02".
+func isDate(s string) bool {
+	if len(s) != 10 || s[4] != '-' || s[7] != '-' {
+		return false
+	}
+	for _, i := range []int{0, 1, 2, 3, 5, 6, 8, 9} {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	_, err := time.Parse(dateLayout, s)
+	return err == nil
+}
+
+const dateLayout = "2006-01-02"
+
+func isHex(c byte) bool {
+	return isDigit(c) || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// UUID is a universally unique identifier, see ParamUUID.
+type UUID [16]byte
+
+// String returns the UUID in its canonical form, in lower case.
+func (u UUID) String() string {
+	var b [36]byte
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+	return string(b[:])
+}
+
+// ParamError reports a path parameter that is missing, or whose value is
+// not of the type that the handler asked for. When it is passed on to
+// WriteErrorJSON with http.StatusBadRequest, the response describes the
+// parameter, as in
+//
+//	{"code":400,"status":"Bad Request","error":{"param":"id","value":"x","type":"int","message":"..."}}
+type ParamError struct {
+	Name  string // the name of the parameter
+	Value string // the value of the parameter, which is empty if it is missing
+	Type  string // the type that the value was converted to
+}
+
+func (e *ParamError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("netkit: missing path parameter %q", e.Name)
+	}
+	return fmt.Sprintf("netkit: path parameter %q: %q is not a valid %s", e.Name, e.Value, e.Type)
+}
+
+// MarshalJSON encodes the error along with its message.
+func (e *ParamError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Name    string `json:"param"`
+		Value   string `json:"value"`
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	}{e.Name, e.Value, e.Type, e.Error()})
+}
+
+// typedParam returns the value of the named path parameter of the request,
+// if it passes the check for the type, and otherwise a *ParamError.
+func typedParam(r *http.Request, name, typ string) (string, error) {
+	v, _ := ParamsFromContext(r.Context()).Lookup(name)
+	if v == "" || !paramTypes[typ](v) {
+		return "", &ParamError{Name: name, Value: v, Type: typ}
+	}
+	return v, nil
+}
+
+// ParamInt returns the value of the named path parameter as an int. If the
+// parameter is missing, or its value is not an integer, it returns a
+// *ParamError, which the handler can answer with a 400 Bad Request using
+// WriteErrorJSON. The values of parameters declared as "{name:int}" are
+// checked while matching, so the conversion only fails for a route that
+// was registered without the type.
+func ParamInt(r *http.Request, name string) (int, error) {
+	v, err := typedParam(r, name, "int")
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, &ParamError{Name: name, Value: v, Type: "int"}
+	}
+	return n, nil
+}
+
+// ParamUUID returns the value of the named path parameter as a UUID, see
+// ParamInt. Parameters may be declared as "{name:uuid}".
+func ParamUUID(r *http.Request, name string) (UUID, error) {
+	var u UUID
+	v, err := typedParam(r, name, "uuid")
+	if err != nil {
+		return u, err
+	}
+	var b [32]byte
+	copy(b[0:8], v[0:8])
+	copy(b[8:12], v[9:13])
+	copy(b[12:16], v[14:18])
+	copy(b[16:20], v[19:23])
+	copy(b[20:], v[24:])
+	hex.Decode(u[:], b[:])
+	return u, nil
+}
+
+// ParamDate returns the value of the named path parameter as the midnight
+// in UTC of the date, see ParamInt. Parameters may be declared as
+// "{name:date}", and their values are written as "2006-01-02".
+func ParamDate(r *http.Request, name string) (time.Time, error) {
+	v, err := typedParam(r, name, "date")
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(dateLayout, v)
+}
@@ -0,0 +1,113 @@
+package netkit
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Jonny-Burkholder/streaming-example/pkg/assert"
+)
+
+func TestParamTypes(t *testing.T) {
+	tests := []struct {
+		typ   string
+		value string
+		valid bool
+	}{
+		{"int", "42", true},
+		{"int", "-7", true},
+		{"int", "9223372036854775807", true},
+		{"int", "9223372036854775808", false},
+		{"int", "", false},
+		{"int", "-", false},
+		{"int", "4x", false},
+		{"int", "+4", false},
+		{"uuid", "f47ac10b-58cc-4372-a567-0e02b2c3d479", true},
+		{"uuid", "F47AC10B-58CC-4372-A567-0E02B2C3D479", true},
+		{"uuid", "f47ac10b58cc4372a5670e02b2c3d479", false},
+		{"uuid", "g47ac10b-58cc-4372-a567-0e02b2c3d479", false},
+		{"date", "2024-02-29", true},
+		{"date", "2023-02-29", false},
+		{"date", "2024-2-29", false},
+		{"date", "today", false},
+	}
+	for _, tt := range tests {
+		if got := paramTypes[tt.typ](tt.value); got != tt.valid {
+			t.Errorf("%s(%q): expected %v, got %v", tt.typ, tt.value, tt.valid, got)
+		}
+	}
+}
+
+func TestTypedParams_Routing(t *testing.T) {
+	for _, r := range []RouterInterface{newTestRouter(), NewRouterV2()} {
+		r.Get("/tracks/{id:int}", func(w http.ResponseWriter, r *http.Request) {
+			id, err := ParamInt(r, "id")
+			fmt.Fprintf(w, "int %d %v", id, err)
+		})
+		r.Get("/tracks/{id:uuid}", func(w http.ResponseWriter, r *http.Request) {
+			id, err := ParamUUID(r, "id")
+			fmt.Fprintf(w, "uuid %s %v", id, err)
+		})
+		r.Get("/tracks/{day:date}", func(w http.ResponseWriter, r *http.Request) {
+			day, err := ParamDate(r, "day")
+			fmt.Fprintf(w, "date %s %v", day.Format(time.RFC3339), err)
+		})
+		r.Get("/tracks/:name", paramsHandler("name", "name"))
+
+		tests := []struct {
+			path string
+			body string
+		}{
+			{"/tracks/42", "int 42 <nil>"},
+			{"/tracks/-1", "int -1 <nil>"},
+			{"/tracks/F47AC10B-58CC-4372-A567-0E02B2C3D479", "uuid f47ac10b-58cc-4372-a567-0e02b2c3d479 <nil>"},
+			{"/tracks/2024-02-29", "date 2024-02-29T00:00:00Z <nil>"},
+			{"/tracks/2023-02-29", "name name=2023-02-29"},
+			{"/tracks/intro", "name name=intro"},
+		}
+		for _, tt := range tests {
+			assert.Equal(t, tt.body, serve(r, "GET", tt.path).Body.String())
+		}
+	}
+}
+
+func TestParamInt_BadRequest(t *testing.T) {
+	rt := NewRouterV2()
+	rt.Get("/tracks/:id", func(w http.ResponseWriter, r *http.Request) {
+		id, err := ParamInt(r, "id")
+		if err != nil {
+			WriteErrorJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+		fmt.Fprint(w, id)
+	})
+	rt.Get("/albums", func(w http.ResponseWriter, r *http.Request) {
+		_, err := ParamUUID(r, "id")
+		fmt.Fprint(w, err)
+	})
+
+	w := serve(rt, "GET", "/tracks/abc")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, `{"code":400,"status":"Bad Request","error":{"param":"id","value":"abc","type":"int","message":"netkit: path parameter \"id\": \"abc\" is not a valid int"}}`+"\n", w.Body.String())
+	assert.Equal(t, "7", serve(rt, "GET", "/tracks/7").Body.String())
+	assert.Equal(t, `netkit: missing path parameter "id"`, serve(rt, "GET", "/albums").Body.String())
+}
+
+func TestTypedParams_OpenAPI(t *testing.T) {
+	rt := NewRouterV2()
+	rt.Get("/tracks/{id:int}/plays/{day:date}", paramsHandler("plays"))
+	doc := NewOpenAPI(OpenAPIInfo{Title: "stream"}, rt.Routes())
+	params := doc.Paths["/tracks/{id}/plays/{day}"]["get"].Parameters
+	assert.Equal(t, &Schema{Type: "integer", Format: "int64"}, params[0].Schema)
+	assert.Equal(t, &Schema{Type: "string", Format: "date"}, params[1].Schema)
+}
+
+func BenchmarkTypedParams(b *testing.B) {
+	for _, rt := range []RouterInterface{newTestRouter(), NewRouterV2()} {
+		rt.Get("/tracks/{id:int}", func(w http.ResponseWriter, r *http.Request) {})
+		b.Run(fmt.Sprintf("%T", rt), func(b *testing.B) {
+			benchmarkServeHTTP(b, rt, "/tracks/42")
+		})
+	}
+}
@@ -0,0 +1,495 @@
+package netkit
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Jonny-Burkholder/streaming-example/pkg/trees/radix"
+)
+
+// Pattern is a compiled route pattern. Both routers compile the patterns
+// of their routes with CompilePattern, which breaks them up into the static
+// and wildcard segments understood by the radix tree.
+//
+// A parameter is written as either ":name" or "{name}", so "/audio/:id" and
+// "/audio/{id}/info" are both valid patterns. Parameter names may contain
+// letters, digits and '_', so a ":name" ends at the first byte that can not
+// be part of a name. That byte must be a '/', a '.' or a '@', as any other
+// byte, such as the '-' in ":user-id", would leave it unclear where the
+// name ends, so such a pattern is rejected. The braced form may be followed
+// by any literal text, as in "{from}-{to}".
+//
+// A path segment may hold several parameters, as long as they are separated
+// by literal text, as in "/audio/:track.:format" or "/image/{name}@{scale}.png".
+// Such a segment is matched as a whole. Every parameter needs a non-empty
+// value, and a parameter followed by literal text takes the longest value
+// that lets the rest of the segment match, so "/audio/live.at.wembley.mp3"
+// gives "live.at.wembley" for track and "mp3" for format. When constraints
+// rule out the longest value, shorter ones are tried in turn.
+//
+// The braced form may carry a regular expression that constrains the
+// values of the parameter, as in "{id:[0-9]+}" or "{slug:[a-z0-9-]+}".
+// The expression must match the whole value. Routes whose constraints
+// fail are skipped, so the next candidate route is tried. In place of an
+// expression, a parameter can be given one of the types "int", "uuid" or
+// "date", as in "{id:int}", whose values are checked without a regular
+// expression, and read with ParamInt, ParamUUID and ParamDate.
+//
+// A catch-all is written as either "*name" or "{name...}". It captures
+// the rest of the path, slashes included, and must be the last segment
+// of the pattern, as in "/static/*filepath" or "/media/{path...}".
+//
+// A parameter that takes up a whole path segment at the end of the
+// pattern can be made optional with a '?', as in "/v2/audio/:id/:quality?"
+// or "/catalog/{page?}", which also match "/v2/audio/42" and "/catalog".
+// In the braced form, the '?' goes right after the name and before any
+// constraint, as in "{page?:int}" or "{page?:[0-9]+}". A '?' at the end of
+// a constraint is part of its regular expression, so "{page:int?}", which
+// would only be a confusing way to write "in" or "int", is rejected.
+// Only optional parameters may follow an optional parameter, and the
+// value of an optional parameter that is absent from the path is empty.
+// Routers register such a pattern as each of its variants, see variants,
+// so the variants take part in conflict detection like any other pattern.
+type Pattern struct {
+	raw      string
+	segs     []radix.Segment
+	mixed    []mixedSegment // the pieces of each Mixed segment, in order
+	names    []string
+	path     []part     // the path segments, see parts
+	optional []int      // where the segment of each optional parameter starts
+	shorter  []*Pattern // the pattern without its optional parameters, see variants
+}
+
+// CompilePattern parses the provided pattern, and returns an error if the
+// pattern is malformed.
+func CompilePattern(p string) (*Pattern, error) {
+	if p == "" {
+		return nil, fmt.Errorf("netkit: invalid pattern %q: empty pattern", p)
+	}
+	pat := &Pattern{raw: p}
+	var static strings.Builder
+	var cur mixedSegment // the pieces of the current path segment
+	i := 0
+	for i < len(p) {
+		c := p[i]
+		if c == '/' {
+			if len(pat.optional) > 0 && i == len(p)-1 {
+				return nil, errOptional(p)
+			}
+			pat.addSegment(&static, cur)
+			cur = cur[:0:0]
+			static.WriteByte(c)
+			i++
+			continue
+		}
+		if c != ':' && c != '{' && c != '*' {
+			if len(pat.optional) > 0 {
+				return nil, errOptional(p)
+			}
+			j := i + 1
+			for j < len(p) && strings.IndexByte("/:{*", p[j]) == -1 {
+				j++
+			}
+			cur = append(cur, piece{text: p[i:j]})
+			i = j
+			continue
+		}
+		var name, expr string
+		var optional bool
+		kind, start := radix.Param, i
+		switch c {
+		case ':':
+			j := i + 1
+			for j < len(p) && isBoth(p[j]) {
+				j++
+			}
+			name = p[i+1 : j]
+			if j < len(p) && p[j] == '?' {
+				optional = true
+				j++
+			} else if j < len(p) && name != "" && strings.IndexByte("/.@:{*", p[j]) == -1 {
+				return nil, fmt.Errorf("netkit: invalid pattern %q: %q may not be followed by %q, write \"{%s}%c\" to follow the parameter with literal text", p, ":"+name, p[j], name, p[j])
+			}
+			i = j
+		case '*':
+			j := i + 1
+			for j < len(p) && p[j] != '/' {
+				j++
+			}
+			name = p[i+1 : j]
+			i = j
+			kind = radix.CatchAll
+		case '{':
+			j := closingBrace(p, i)
+			if j == -1 {
+				return nil, fmt.Errorf("netkit: invalid pattern %q: missing closing '}'", p)
+			}
+			name = p[i+1 : j]
+			i = j + 1
+			if k := strings.IndexByte(name, ':'); k != -1 {
+				name, expr = name[:k], name[k+1:]
+				if expr == "" {
+					return nil, fmt.Errorf("netkit: invalid pattern %q: empty constraint for parameter %q", p, name)
+				}
+			}
+			if strings.HasSuffix(name, "?") {
+				name = name[:len(name)-1]
+				optional = true
+			}
+			if _, ok := paramTypes[strings.TrimSuffix(expr, "?")]; ok && expr != strings.TrimSuffix(expr, "?") {
+				return nil, fmt.Errorf("netkit: invalid pattern %q: write \"{%s?:%s}\" to make parameter %q optional", p, name, expr[:len(expr)-1], name)
+			}
+			if expr == "" && strings.HasSuffix(name, "...") {
+				name = name[:len(name)-3]
+				kind = radix.CatchAll
+			}
+		}
+		switch {
+		case optional && kind == radix.CatchAll:
+			return nil, fmt.Errorf("netkit: invalid pattern %q: a catch-all can not be optional", p)
+		case optional && (len(cur) > 0 || start == 0 || p[start-1] != '/' || (i < len(p) && p[i] != '/')):
+			return nil, fmt.Errorf("netkit: invalid pattern %q: optional parameter %q must take up a whole path segment", p, name)
+		case optional:
+			pat.optional = append(pat.optional, start-1)
+		case len(pat.optional) > 0:
+			return nil, errOptional(p)
+		}
+		if kind == radix.CatchAll && (len(cur) > 0 || i < len(p)) {
+			return nil, fmt.Errorf("netkit: invalid pattern %q: catch-all must be the last segment", p)
+		}
+		if n := len(cur); n > 0 && cur[n-1].param {
+			return nil, fmt.Errorf("netkit: invalid pattern %q: parameters %q and %q must be separated by literal text", p, cur[n-1].text, name)
+		}
+		if err := checkParamName(name); err != nil {
+			return nil, fmt.Errorf("netkit: invalid pattern %q: %w", p, err)
+		}
+		for _, n := range pat.names {
+			if n == name {
+				return nil, fmt.Errorf("netkit: invalid pattern %q: duplicate parameter %q", p, name)
+			}
+		}
+		pc := piece{text: name, param: true, kind: kind, expr: expr}
+		if expr != "" {
+			match, err := compileConstraint(expr)
+			if err != nil {
+				return nil, fmt.Errorf("netkit: invalid pattern %q: bad constraint for parameter %q: %w", p, name, err)
+			}
+			pc.match = match
+		}
+		cur = append(cur, pc)
+		pat.names = append(pat.names, name)
+	}
+	pat.addSegment(&static, cur)
+	if static.Len() > 0 {
+		pat.segs = append(pat.segs, radix.Segment{Kind: radix.Static, Text: static.String()})
+	}
+	pat.path = pat.parts(false)
+	for i := len(pat.optional) - 1; i >= 0; i-- {
+		raw := p[:pat.optional[i]]
+		if raw == "" {
+			raw = "/"
+		}
+		v, err := CompilePattern(raw)
+		if err != nil {
+			return nil, err
+		}
+		pat.shorter = append(pat.shorter, v)
+	}
+	return pat, nil
+}
+
+func errOptional(p string) error {
+	return fmt.Errorf("netkit: invalid pattern %q: only optional parameters may follow an optional parameter", p)
+}
+
+// variants returns the patterns that the pattern stands for: the pattern
+// itself, with all of its optional parameters present, followed by the
+// patterns that leave out its optional parameters one at a time, starting
+// from the last one. A pattern without optional parameters is its only
+// variant.
+func (p *Pattern) variants() []*Pattern {
+	return append([]*Pattern{p}, p.shorter...)
+}
+
+// addSegment adds the path segment made up of the pieces to the pattern.
+// Literal text is added to the static text that precedes the next wildcard,
+// a lone parameter becomes a wildcard of its own, and a segment that mixes
+// parameters with literal text becomes a Mixed wildcard.
+func (p *Pattern) addSegment(static *strings.Builder, pieces mixedSegment) {
+	if pieces.params() == 0 {
+		for _, pc := range pieces {
+			static.WriteString(pc.text)
+		}
+		return
+	}
+	if static.Len() > 0 {
+		p.segs = append(p.segs, radix.Segment{Kind: radix.Static, Text: static.String()})
+		static.Reset()
+	}
+	if len(pieces) == 1 {
+		pc := pieces[0]
+		p.segs = append(p.segs, radix.Segment{Kind: pc.kind, Text: pc.expr, Match: pc.match})
+		return
+	}
+	p.segs = append(p.segs, radix.Segment{Kind: radix.Mixed, Text: pieces.shape(), Capture: pieces.capture})
+	p.mixed = append(p.mixed, pieces)
+}
+
+// String returns the pattern as it was written.
+func (p *Pattern) String() string {
+	return p.raw
+}
+
+// Match reports whether the path matches the pattern, and returns the values
+// captured by its parameters. A catch-all matches the rest of the path, even
+// when it is empty, and optional parameters that are absent from the path
+// are returned with an empty value.
+func (p *Pattern) Match(path string) (Params, bool) {
+	var buf [8]string
+	vals, ok := matchParts(p.path, path, buf[:0])
+	for i := 0; !ok && i < len(p.shorter); i++ {
+		vals, ok = matchParts(p.shorter[i].path, path, buf[:0])
+	}
+	if !ok || len(p.names) == 0 {
+		return nil, ok
+	}
+	ps := make(Params, len(p.names))
+	for i, name := range p.names {
+		ps[i].Key = name
+		if i < len(vals) {
+			ps[i].Value = vals[i]
+		}
+	}
+	return ps, true
+}
+
+// Build fills in the parameters of the pattern with the values of ps, and
+// returns the resulting path. The values are escaped. Build panics if a
+// value is missing, if ps holds a value for a parameter that the pattern
+// does not have, or if a value does not satisfy its constraint; Router.URL
+// and RouterV2.URL return an error instead.
+func (p *Pattern) Build(ps Params) string {
+	path, err := p.build(ps)
+	if err != nil {
+		panic(fmt.Sprintf("netkit: pattern %q: %s", p.raw, err))
+	}
+	return path
+}
+
+// Specificity returns the specificity of the pattern, which decides the
+// precedence of routes whose patterns match the same path.
+func (p *Pattern) Specificity() Specificity {
+	return Specificity{parts: p.path}
+}
+
+// matchParts reports whether the path matches the pattern made up of the
+// parts, one path segment at a time, and returns the values captured by its
+// parameters appended to vals.
+func matchParts(parts []part, path string, vals []string) ([]string, bool) {
+	n := len(vals)
+	for i, pt := range parts {
+		if pt.kind == radix.CatchAll {
+			return append(vals, path), true
+		}
+		seg, rest, more := strings.Cut(path, "/")
+		if more != (i < len(parts)-1) {
+			return vals[:n], false
+		}
+		switch {
+		case pt.kind == radix.Mixed:
+			vs, ok := pt.capture(seg, vals)
+			if !ok {
+				return vals[:n], false
+			}
+			vals = vs
+		case !pt.accepts(seg):
+			return vals[:n], false
+		case pt.kind == radix.Param:
+			vals = append(vals, seg)
+		}
+		path = rest
+	}
+	return vals, true
+}
+
+// closingBrace returns the index of the '}' that closes the '{' found at
+// p[i], taking nested braces into account, or -1 if there is none.
+func closingBrace(p string, i int) int {
+	depth := 0
+	for ; i < len(p); i++ {
+		switch p[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// isStatic reports whether the pattern contains no wildcards at all.
+func (p *Pattern) isStatic() bool {
+	return len(p.names) == 0
+}
+
+// catchAllPrefix reports whether the pattern consists of static text
+// followed by a catch-all, and if so, returns the static text.
+func (p *Pattern) catchAllPrefix() (string, bool) {
+	n := len(p.segs)
+	if n == 0 || p.segs[n-1].Kind != radix.CatchAll || len(p.names) != 1 {
+		return "", false
+	}
+	if n == 1 {
+		return "", true
+	}
+	return p.segs[0].Text, true
+}
+
+// shape returns the pattern with its parameter names left out, so that
+// patterns matching exactly the same paths, such as "/audio/:id" and
+// "/audio/{track}", share the same shape. The shape of a static pattern
+// is the pattern itself.
+func (p *Pattern) shape() string {
+	if p.isStatic() {
+		return p.raw
+	}
+	var sb strings.Builder
+	for _, seg := range p.segs {
+		switch seg.Kind {
+		case radix.Static:
+			sb.WriteString(seg.Text)
+		case radix.CatchAll:
+			sb.WriteString("{...}")
+		default:
+			if seg.Text != "" {
+				sb.WriteString("{:" + seg.Text + "}")
+			} else {
+				sb.WriteString("{}")
+			}
+		}
+	}
+	return sb.String()
+}
+
+// parts splits the pattern into its path segments, each of which is either
+// static text or a wildcard. When prefix is true, and the pattern is static
+// and ends in a '/', its last segment is turned into a catch-all.
+func (p *Pattern) parts(prefix bool) []part {
+	var parts []part
+	cur := part{kind: radix.Static}
+	for _, seg := range p.segs {
+		if seg.Kind != radix.Static {
+			cur = part{kind: seg.Kind, text: seg.Text, match: seg.Match, capture: seg.Capture}
+			continue
+		}
+		pieces := strings.Split(seg.Text, "/")
+		cur.text += pieces[0]
+		for _, piece := range pieces[1:] {
+			parts = append(parts, cur)
+			cur = part{kind: radix.Static, text: piece}
+		}
+	}
+	if prefix && p.isStatic() && strings.HasSuffix(p.raw, "/") {
+		cur.kind = radix.CatchAll
+	}
+	return append(parts, cur)
+}
+
+// piece is a piece of a path segment, which is either literal text, or a
+// parameter with its name and its optional constraint.
+type piece struct {
+	text  string // the literal text, or the name of the parameter
+	param bool
+	kind  radix.Kind // the kind of the parameter
+	expr  string
+	match func(string) bool
+}
+
+// mixedSegment is a path segment that holds parameters along with literal
+// text, such as "{name}.{ext}". A parameter is always followed by literal
+// text or by the end of the segment, see CompilePattern.
+type mixedSegment []piece
+
+// shape returns the segment with its parameter names left out, see
+// Pattern.shape.
+func (m mixedSegment) shape() string {
+	var sb strings.Builder
+	for _, pc := range m {
+		switch {
+		case !pc.param:
+			sb.WriteString(pc.text)
+		case pc.expr != "":
+			sb.WriteString("{:" + pc.expr + "}")
+		default:
+			sb.WriteString("{}")
+		}
+	}
+	return sb.String()
+}
+
+// params returns the number of parameters in the segment.
+func (m mixedSegment) params() int {
+	n := 0
+	for _, pc := range m {
+		if pc.param {
+			n++
+		}
+	}
+	return n
+}
+
+// capture matches the path segment s against the pieces, and returns the
+// values of the parameters appended to vals. A parameter followed by
+// literal text takes the longest value that lets the rest of the segment
+// match.
+func (m mixedSegment) capture(s string, vals []string) ([]string, bool) {
+	if len(m) == 0 {
+		return vals, s == ""
+	}
+	pc := m[0]
+	if !pc.param {
+		if !strings.HasPrefix(s, pc.text) {
+			return vals, false
+		}
+		return m[1:].capture(s[len(pc.text):], vals)
+	}
+	if len(m) == 1 {
+		if s == "" || (pc.match != nil && !pc.match(s)) {
+			return vals, false
+		}
+		return append(vals, s), true
+	}
+	lit := m[1].text
+	for end := strings.LastIndex(s, lit); end > 0; end = strings.LastIndex(s[:end], lit) {
+		if pc.match != nil && !pc.match(s[:end]) {
+			continue
+		}
+		if vs, ok := m[2:].capture(s[end+len(lit):], append(vals, s[:end])); ok {
+			return vs, true
+		}
+	}
+	return vals, false
+}
+
+// checkParamName returns an error if name is not a valid parameter name.
+func checkParamName(name string) error {
+	if name == "" {
+		return fmt.Errorf("empty parameter name")
+	}
+	for i := 0; i < len(name); i++ {
+		if !isBoth(name[i]) {
+			return fmt.Errorf("invalid character %q in parameter name %q", name[i], name)
+		}
+	}
+	return nil
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+func isBoth(c byte) bool {
+	return ('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_') || ('0' <= c && c <= '9')
+}
@@ -0,0 +1,343 @@
+package netkit
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"testing"
+
+	"github.com/Jonny-Burkholder/streaming-example/pkg/assert"
+)
+
+func TestPattern_Match(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		match   bool
+		params  Params
+	}{
+		{"/api/users", "/api/users", true, nil},
+		{"/api/users", "/api/users/", false, nil},
+		{"/api/users/:id", "/api/users/12", true, Params{{"id", "12"}}},
+		{"/api/users/:id", "/api/users/", false, nil},
+		{"/api/users/:id", "/api/users/12/foo", false, nil},
+		{"/api/users/{id}/", "/api/users/79/", true, Params{{"id", "79"}}},
+		{"/api/users/{id:[0-9]+}/foo", "/api/users/123456/foo", true, Params{{"id", "123456"}}},
+		{"/api/users/{id:[0-9]+}/foo", "/api/users/bob/foo", false, nil},
+		{"/api/jobs/{id:[0-9]{2}}", "/api/jobs/123", false, nil},
+		{"/static/*path", "/static/css/site.css", true, Params{{"path", "css/site.css"}}},
+		{"/static/{path...}", "/static/", true, Params{{"path", ""}}},
+		{"/static/*path", "/static", false, nil},
+		{"/users/:user/files/*rest", "/users/bob/files/a/b", true, Params{{"user", "bob"}, {"rest", "a/b"}}},
+		{"/audio/:track.:format", "/audio/intro.mp3", true, Params{{"track", "intro"}, {"format", "mp3"}}},
+		{"/audio/:track.:format", "/audio/live.at.wembley.mp3", true, Params{{"track", "live.at.wembley"}, {"format", "mp3"}}},
+		{"/audio/:track.:format", "/audio/intro", false, nil},
+		{"/audio/:track.:format", "/audio/.mp3", false, nil},
+		{"/audio/:track.:format", "/audio/intro.", false, nil},
+		{"/audio/{track}.{format:[a-z0-9]+}", "/audio/a.b.tar", true, Params{{"track", "a.b"}, {"format", "tar"}}},
+		{"/audio/{track:[a-z]+}.{format}", "/audio/intro.tar.gz", true, Params{{"track", "intro"}, {"format", "tar.gz"}}},
+		{"/audio/{id:int}.json", "/audio/42.json", true, Params{{"id", "42"}}},
+		{"/audio/{id:int}.json", "/audio/x.json", false, nil},
+		{"/image/:name@:scale.png", "/image/logo@2x.png", true, Params{{"name", "logo"}, {"scale", "2x"}}},
+		{"/image/:name@:scale.png", "/image/me@home@2x.png", true, Params{{"name", "me@home"}, {"scale", "2x"}}},
+		{"/image/:name@:scale.png", "/image/logo@2x.jpg", false, nil},
+		{"/v:major/users", "/v2/users", true, Params{{"major", "2"}}},
+		{"/v2/audio/:id/:quality?", "/v2/audio/42/hifi", true, Params{{"id", "42"}, {"quality", "hifi"}}},
+		{"/v2/audio/:id/:quality?", "/v2/audio/42", true, Params{{"id", "42"}, {"quality", ""}}},
+		{"/v2/audio/:id/:quality?", "/v2/audio/42/", false, nil},
+		{"/v2/audio/:id/:quality?", "/v2/audio", false, nil},
+		{"/catalog/{page?:int}/{size?}", "/catalog", true, Params{{"page", ""}, {"size", ""}}},
+		{"/catalog/{page?:int}/{size?}", "/catalog/2", true, Params{{"page", "2"}, {"size", ""}}},
+		{"/catalog/{page?:int}/{size?}", "/catalog/2/50", true, Params{{"page", "2"}, {"size", "50"}}},
+		{"/catalog/{page?:int}/{size?}", "/catalog/x", false, nil},
+		{"/{page?}", "/", true, Params{{"page", ""}}},
+		{"/{page?}", "/about", true, Params{{"page", "about"}}},
+	}
+	for _, tt := range tests {
+		pat, err := CompilePattern(tt.pattern)
+		if err != nil {
+			t.Fatalf("CompilePattern(%q): unexpected error: %s", tt.pattern, err)
+		}
+		ps, ok := pat.Match(tt.path)
+		if ok != tt.match {
+			t.Errorf("%q.Match(%q): expected %v, got %v", tt.pattern, tt.path, tt.match, ok)
+			continue
+		}
+		assert.Equal(t, tt.params, ps)
+	}
+}
+
+func TestPattern_Build(t *testing.T) {
+	pat, err := CompilePattern("/users/{id:[0-9]+}/files/*rest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "/users/42/files/a%20b/c", pat.Build(Params{{"id", "42"}, {"rest", "a b/c"}}))
+	assert.Equal(t, "/users/42/files/", pat.Build(Params{{"id", "42"}, {"rest", ""}}))
+
+	for _, ps := range []Params{
+		{{"rest", "a"}},
+		{{"id", "x"}, {"rest", "a"}},
+		{{"id", "42"}, {"rest", "a"}, {"name", "bob"}},
+	} {
+		func() {
+			defer func() {
+				assert.Equal(t, true, recover() != nil)
+			}()
+			pat.Build(ps)
+		}()
+	}
+}
+
+func TestPattern_BuildMixed(t *testing.T) {
+	pat, err := CompilePattern("/image/{name}@{scale:[0-9]x}.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "/image/logo@2x.png", pat.Build(Params{{"name", "logo"}, {"scale", "2x"}}))
+	assert.Equal(t, "/image/me@home@2x.png", pat.Build(Params{{"name", "me@home"}, {"scale", "2x"}}))
+
+	pat, _ = CompilePattern("/audio/:track.:format")
+	assert.Equal(t, "/audio/live.at.wembley.mp3", pat.Build(Params{{"track", "live.at.wembley"}, {"format", "mp3"}}))
+	for _, ps := range []Params{
+		{{"track", "intro"}},
+		{{"track", ""}, {"format", "mp3"}},
+		{{"track", "a/b"}, {"format", "mp3"}},
+		// "intro.mp3" would be matched as the format of track "intro.tar"
+		{{"track", "intro"}, {"format", "tar.gz"}},
+	} {
+		_, err := pat.build(ps)
+		if err == nil {
+			t.Errorf("build(%v): expected an error", ps)
+		}
+	}
+}
+
+func TestPattern_BuildOptional(t *testing.T) {
+	pat, err := CompilePattern("/catalog/{page?:int}/{size?}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "/catalog/2/50", pat.Build(Params{{"page", "2"}, {"size", "50"}}))
+	assert.Equal(t, "/catalog/2", pat.Build(Params{{"page", "2"}}))
+	assert.Equal(t, "/catalog/2", pat.Build(Params{{"page", "2"}, {"size", ""}}))
+	assert.Equal(t, "/catalog", pat.Build(nil))
+	for _, ps := range []Params{
+		{{"size", "50"}},
+		{{"page", "x"}},
+		{{"page", "2"}, {"sort", "asc"}},
+	} {
+		if _, err := pat.build(ps); err == nil {
+			t.Errorf("build(%v): expected an error", ps)
+		}
+	}
+}
+
+func TestOptionalParams_Routing(t *testing.T) {
+	for _, r := range []RouterInterface{newTestRouter(), NewRouterV2()} {
+		r.Get("/v2/audio/:id/:quality?", paramsHandler("audio", "id", "quality"), WithName("audio"))
+		r.Get("/v2/audio/:id/info", paramsHandler("info", "id"))
+		r.Get("/catalog/{page?:int}", paramsHandler("catalog", "page"))
+		r.Get("/archive/{year?:[0-9]{4}}", paramsHandler("archive", "year"))
+
+		tests := []struct {
+			path string
+			code int
+			body string
+		}{
+			{"/v2/audio/42", 200, "audio id=42 quality="},
+			{"/v2/audio/42/hifi", 200, "audio id=42 quality=hifi"},
+			{"/v2/audio/42/info", 200, "info id=42"},
+			{"/catalog", 200, "catalog page="},
+			{"/catalog/3", 200, "catalog page=3"},
+			{"/catalog/x", 404, ""},
+			{"/archive", 200, "archive year="},
+			{"/archive/2024", 200, "archive year=2024"},
+			{"/archive/24", 404, ""},
+		}
+		for _, tt := range tests {
+			w := serve(r, "GET", tt.path)
+			assert.Equal(t, tt.code, w.Code)
+			if tt.code == 200 {
+				assert.Equal(t, tt.body, w.Body.String())
+			}
+		}
+
+		url, err := r.URL("audio", "id", "42")
+		assert.Equal(t, "/v2/audio/42", url)
+		assert.Equal(t, nil, err)
+		url, _ = r.URL("audio", "id", "42", "quality", "hifi")
+		assert.Equal(t, "/v2/audio/42/hifi", url)
+	}
+}
+
+func TestOptionalParams_Registration(t *testing.T) {
+	for _, r := range []RouterInterface{newTestRouter(), NewRouterV2()} {
+		r.Get("/catalog", paramsHandler("catalog"))
+
+		// the variant without the page is a duplicate of "/catalog"
+		v := func() (v any) {
+			defer func() {
+				v = recover()
+			}()
+			r.Get("/catalog/{page?}", paramsHandler("page"))
+			return nil
+		}()
+		err, ok := v.(*ConflictError)
+		if !ok {
+			t.Fatalf("%T: expected a *ConflictError, got %v", r, v)
+		}
+		assert.Equal(t, "/catalog/{page?}", err.Route.Pattern)
+		assert.Equal(t, "/catalog", err.Existing.Pattern)
+		assert.Equal(t, http.StatusNotFound, serve(r, "GET", "/catalog/2").Code)
+
+		r.Post("/albums/{page?}", paramsHandler("albums"))
+		r.Post("/albums/{id:int}", paramsHandler("album"))
+		var patterns []string
+		for _, ri := range r.Routes() {
+			patterns = append(patterns, ri.Method+" "+ri.Pattern)
+		}
+		sort.Strings(patterns)
+		assert.Equal(t, []string{"GET /catalog", "POST /albums/{id:int}", "POST /albums/{page?}"}, patterns)
+		errs, _ := r.Validate().(ConflictErrors)
+		assert.Equal(t, 1, len(errs))
+		assert.Equal(t, "/albums/{id:int}", errs[0].Route.Pattern)
+
+		assert.Equal(t, true, r.Unregister(http.MethodPost, "/albums/{page?}"))
+		assert.Equal(t, "album", serve(r, "POST", "/albums/2").Body.String())
+		assert.Equal(t, http.StatusNotFound, serve(r, "POST", "/albums").Code)
+	}
+}
+
+func TestOptionalParams_OpenAPI(t *testing.T) {
+	rt := NewRouterV2()
+	rt.Get("/v2/audio/:id/:quality?", paramsHandler("audio"), WithName("audio"))
+	doc := NewOpenAPI(OpenAPIInfo{Title: "stream"}, rt.Routes())
+	full := doc.Paths["/v2/audio/{id}/{quality}"]["get"]
+	short := doc.Paths["/v2/audio/{id}"]["get"]
+	assert.Equal(t, 2, len(full.Parameters))
+	assert.Equal(t, "audio", full.OperationID)
+	assert.Equal(t, 1, len(short.Parameters))
+	assert.Equal(t, "", short.OperationID)
+}
+
+func TestMixedSegments_Routing(t *testing.T) {
+	for _, r := range []RouterInterface{newTestRouter(), NewRouterV2()} {
+		r.Get("/audio/:track.:format", paramsHandler("file", "track", "format"))
+		r.Get("/audio/{id:int}.json", paramsHandler("json", "id"))
+		r.Get("/audio/:id", paramsHandler("track", "id"))
+		r.Get("/audio/latest.mp3", paramsHandler("latest"))
+		r.Get("/image/:name@:scale.png", paramsHandler("image", "name", "scale"))
+		r.Get("/image/:name.png", paramsHandler("plain", "name"))
+
+		tests := []struct {
+			path string
+			code int
+			body string
+		}{
+			{"/audio/intro.mp3", 200, "file track=intro format=mp3"},
+			{"/audio/live.at.wembley.mp3", 200, "file track=live.at.wembley format=mp3"},
+			{"/audio/42.json", 200, "json id=42"},
+			{"/audio/x.json", 200, "file track=x format=json"},
+			{"/audio/latest.mp3", 200, "latest"},
+			{"/audio/intro", 200, "track id=intro"},
+			{"/image/logo@2x.png", 200, "image name=logo scale=2x"},
+			{"/image/logo.png", 200, "plain name=logo"},
+			{"/image/logo@2x.jpg", 404, ""},
+			{"/image/@2x.png", 200, "plain name=@2x"},
+		}
+		for _, tt := range tests {
+			w := serve(r, "GET", tt.path)
+			assert.Equal(t, tt.code, w.Code)
+			if tt.code == 200 {
+				assert.Equal(t, tt.body, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestMixedSegments_OpenAPI(t *testing.T) {
+	rt := NewRouterV2()
+	rt.Get("/image/{name}@{scale:int}x.png", paramsHandler("image"))
+	doc := NewOpenAPI(OpenAPIInfo{Title: "stream"}, rt.Routes())
+	op, ok := doc.Paths["/image/{name}@{scale}x.png"]["get"]
+	if !ok {
+		t.Fatalf("missing path, got %v", doc.Paths)
+	}
+	assert.Equal(t, 2, len(op.Parameters))
+	assert.Equal(t, "name", op.Parameters[0].Name)
+	assert.Equal(t, &Schema{Type: "integer", Format: "int64"}, op.Parameters[1].Schema)
+}
+
+func TestPattern_Specificity(t *testing.T) {
+	// each pattern is more specific than the ones that follow it
+	patterns := []string{
+		"/users/new",
+		"/users/{id}@{size}.png",
+		"/users/{id:int}.png",
+		"/users/{id}.png",
+		"/users/{id:[0-9]+}",
+		"/users/{id:[a-z]+}",
+		"/users/:id/files",
+		"/users/:id",
+		"/users/*rest",
+	}
+	for i, a := range patterns {
+		pa, _ := CompilePattern(a)
+		for j, b := range patterns {
+			pb, _ := CompilePattern(b)
+			want := 0
+			if i < j {
+				want = 1
+			} else if i > j {
+				want = -1
+			}
+			if got := pa.Specificity().Compare(pb.Specificity()); got != want {
+				t.Errorf("Compare(%q, %q): expected %d, got %d", a, b, want, got)
+			}
+		}
+	}
+	pa, _ := CompilePattern("/users/:id")
+	pb, _ := CompilePattern("/users/{uid}")
+	assert.Equal(t, 0, pa.Specificity().Compare(pb.Specificity()))
+}
+
+func BenchmarkPattern_Match(b *testing.B) {
+	tests := []struct {
+		pattern string
+		path    string
+	}{
+		{"/api/users/jobs/{jobID}", "/api/users/jobs/12"},
+		{"/api/users/jobs/:id", "/api/users/jobs/1234"},
+		{"/api/users/{id}", "/api/users/123456"},
+		{"/api/users/{id}/", "/api/users/79/"},
+		{"/api/users/{id:[0-9]+}/foo", "/api/users/123456/foo"},
+	}
+	pats := make([]*Pattern, len(tests))
+	for i, tt := range tests {
+		pats[i], _ = CompilePattern(tt.pattern)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for j, tt := range tests {
+			if _, ok := pats[j].Match(tt.path); !ok {
+				b.Fatalf("%q.Match(%q): expected a match", tt.pattern, tt.path)
+			}
+		}
+	}
+}
+
+func TestOptionalParams_Constraint(t *testing.T) {
+	// the '?' goes after the name, and not after the constraint
+	_, err := CompilePattern("/catalog/{page:int?}")
+	assert.Equal(t, `netkit: invalid pattern "/catalog/{page:int?}": write "{page?:int}" to make parameter "page" optional`, fmt.Sprint(err))
+
+	// a '?' at the end of a regular expression is part of the expression
+	pat, err := CompilePattern("/shades/{shade:dark(er)?}")
+	assert.Equal(t, nil, err)
+	ps, ok := pat.Match("/shades/darker")
+	assert.Equal(t, true, ok)
+	assert.Equal(t, Params{{"shade", "darker"}}, ps)
+	_, ok = pat.Match("/shades")
+	assert.Equal(t, false, ok)
+}
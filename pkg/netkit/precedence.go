@@ -0,0 +1,112 @@
+package netkit
+
+import (
+	"sort"
+
+	"github.com/Jonny-Burkholder/streaming-example/pkg/trees/radix"
+)
+
+// When more than one route matches a request, every router picks the
+// route to serve it by the same rule, whatever the order the routes were
+// registered in. The patterns are compared one path segment at a time,
+// from left to right, and at the first segment where they differ:
+//
+//  1. an exact static segment beats
+//  2. a segment that mixes parameters with literal text, such as
+//     ":name.:ext", which beats
+//  3. a parameter with a constraint, such as "{id:[0-9]+}", which beats
+//  4. a plain parameter, such as ":id" or "{id}", which beats
+//  5. a catch-all, such as "*path", "{path...}" or a trailing '/' in Router.
+//
+// Of two mixed segments, the one with more literal text wins, so
+// ":name@:scale.png" beats ":name.png". Otherwise, two mixed segments or
+// two constrained parameters are ordered by their constraints and literal
+// text, so the outcome never depends on registration order. Host patterns
+// follow the same rule, label by label, with "*" ranking after a named
+// parameter.
+
+// part is a single path segment of a pattern, see pattern.parts.
+type part struct {
+	kind    radix.Kind
+	text    string
+	match   func(string) bool
+	capture func(string, []string) ([]string, bool) // for a Mixed part
+}
+
+// accepts reports whether the part matches the path segment s.
+func (pt part) accepts(s string) bool {
+	switch pt.kind {
+	case radix.Static:
+		return pt.text == s
+	case radix.Param:
+		return s != "" && (pt.match == nil || pt.match(s))
+	case radix.Mixed:
+		var buf [8]string
+		_, ok := pt.capture(s, buf[:0])
+		return ok
+	}
+	return true
+}
+
+// before reports whether pt takes precedence over other, which differs
+// from it.
+func (pt part) before(other part) bool {
+	if pt.kind == radix.Static || other.kind == radix.Static {
+		if pt.kind == other.kind {
+			return pt.text < other.text
+		}
+		return pt.kind == radix.Static
+	}
+	return radix.Before(pt.segment(), other.segment())
+}
+
+// segment returns the part as a radix segment.
+func (pt part) segment() radix.Segment {
+	return radix.Segment{Kind: pt.kind, Text: pt.text, Match: pt.match}
+}
+
+// Specificity is the specificity of a pattern, see Pattern.Specificity.
+type Specificity struct {
+	parts []part
+}
+
+// Compare returns +1 if s is more specific than other, and so takes
+// precedence over it, -1 if it is less specific, and 0 if both are
+// equally specific, which only happens when their patterns match exactly
+// the same paths.
+func (s Specificity) Compare(other Specificity) int {
+	switch {
+	case precedes(s.parts, other.parts):
+		return 1
+	case precedes(other.parts, s.parts):
+		return -1
+	}
+	return 0
+}
+
+// precedes reports whether the pattern made up of the parts a takes
+// precedence over the pattern made up of the parts b.
+func precedes(a, b []part) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i].kind != b[i].kind || a[i].text != b[i].text {
+			return a[i].before(b[i])
+		}
+	}
+	return len(a) > len(b)
+}
+
+// insertOrdered returns a copy of the list with the table inserted, as the
+// list is kept ordered by precedence. The list may be shared with another
+// snapshot, so it is never changed in place.
+func insertOrdered(ts []*methodTable, t *methodTable) []*methodTable {
+	i := sort.Search(
+		len(ts), func(i int) bool {
+			return precedes(t.parts, ts[i].parts)
+		},
+	)
+	c := make([]*methodTable, len(ts)+1)
+	copy(c, ts[:i])
+	copy(c[i+1:], ts[i:])
+	c[i] = t
+	return c
+}
@@ -0,0 +1,70 @@
+package netkit
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPrecedence(t *testing.T) {
+	patterns := []string{
+		"/users/new",
+		"/users/{id:[0-9]+}",
+		"/users/{hex:[0-9a-f]+}",
+		"/users/:name",
+		"/users/*rest",
+		"/:section/about",
+		"/static/*filepath",
+	}
+	tests := []struct {
+		path    string
+		pattern string
+	}{
+		{"/users/new", "/users/new"},
+		{"/users/42", "/users/{id:[0-9]+}"},
+		{"/users/beef", "/users/{hex:[0-9a-f]+}"},
+		{"/users/john", "/users/:name"},
+		{"/users/john/files", "/users/*rest"},
+		{"/users/", "/users/*rest"},
+		{"/static/about", "/static/*filepath"},
+		{"/company/about", "/:section/about"},
+	}
+	// every order of registration must give the same result
+	orders := [][]int{{0, 1, 2, 3, 4, 5, 6}, {6, 5, 4, 3, 2, 1, 0}, {3, 1, 6, 0, 4, 2, 5}}
+	for _, order := range orders {
+		rm, rt := newTestRouter(), NewRouterV2()
+		for _, i := range order {
+			rm.Get(patterns[i], paramsHandler(patterns[i]))
+			rt.Get(patterns[i], paramsHandler(patterns[i]))
+		}
+		for _, r := range []http.Handler{rm, rt} {
+			for _, tt := range tests {
+				if got := serve(r, "GET", tt.path).Body.String(); got != tt.pattern {
+					t.Errorf("%T, order %v: %s: got %q, want %q", r, order, tt.path, got, tt.pattern)
+				}
+			}
+		}
+	}
+}
+
+func TestHostPrecedence(t *testing.T) {
+	hosts := []string{"*.example.com", "{tenant}.example.com", "{id:[0-9]+}.example.com", "api.example.com"}
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"api.example.com", "api.example.com"},
+		{"42.example.com", "{id:[0-9]+}.example.com"},
+		{"acme.example.com", "{tenant}.example.com"},
+	}
+	for _, order := range [][]int{{0, 1, 2, 3}, {3, 2, 1, 0}} {
+		rm := newTestRouter()
+		for _, i := range order {
+			rm.Host(hosts[i]).Get("/", paramsHandler(hosts[i]))
+		}
+		for _, tt := range tests {
+			if got := serveHost(rm, "GET", tt.host, "/").Body.String(); got != tt.want {
+				t.Errorf("order %v: %s: got %q, want %q", order, tt.host, got, tt.want)
+			}
+		}
+	}
+}
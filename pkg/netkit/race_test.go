@@ -0,0 +1,7 @@
+//go:build race
+
+package netkit
+
+// raceEnabled reports whether the tests were built with the race detector,
+// which makes sync.Pool drop items at random.
+const raceEnabled = true
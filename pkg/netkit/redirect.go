@@ -0,0 +1,146 @@
+package netkit
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Jonny-Burkholder/streaming-example/pkg/trees/radix"
+)
+
+// RouterV2Option configures a RouterV2 when it is created by NewRouterV2.
+type RouterV2Option func(*RouterV2)
+
+// RedirectTrailingSlash redirects a request whose path does not match any
+// route, but would match one with the trailing slash added or removed, to
+// that path. Router has the same option in its Config.
+func RedirectTrailingSlash() RouterV2Option {
+	return func(rt *RouterV2) {
+		rt.paths.trailingSlash = true
+	}
+}
+
+// RedirectFixedPath redirects a request whose path does not match any route,
+// but would match one once it is cleaned up by removing repeated slashes and
+// resolving "." and ".." elements, to the cleaned up path. Router has the
+// same option in its Config.
+func RedirectFixedPath() RouterV2Option {
+	return func(rt *RouterV2) {
+		rt.paths.fixedPath = true
+	}
+}
+
+// CaseInsensitivePaths redirects a request whose path does not match any
+// route, but would match one if the static parts of its pattern were compared
+// without regard to case, to the path spelled the way it was registered. The
+// values of the parameters are kept as they are. Router has the same option
+// in its Config.
+func CaseInsensitivePaths() RouterV2Option {
+	return func(rt *RouterV2) {
+		rt.paths.caseFold = true
+	}
+}
+
+// pathPolicy decides what happens to a request whose path does not match
+// any route as it is.
+type pathPolicy struct {
+	trailingSlash bool
+	fixedPath     bool
+	caseFold      bool
+}
+
+// redirect returns the canonical path that a request for path should be
+// redirected to, if the policy allows for one. The serves function reports
+// whether the router has a route that would serve the request at a path, and
+// tables returns every method table of the router, for folding the case.
+func (p pathPolicy) redirect(path string, serves func(string) bool, tables func() []*methodTable) (string, bool) {
+	if !p.trailingSlash && !p.fixedPath && !p.caseFold {
+		return "", false
+	}
+	if p.fixedPath {
+		path = cleanPath(path)
+	}
+	candidates := []string{path}
+	if p.trailingSlash {
+		candidates = append(candidates, toggleSlash(path))
+	}
+	var ts []*methodTable
+	if p.caseFold {
+		ts = tables()
+		sort.Slice(ts, func(i, j int) bool { return precedes(ts[i].parts, ts[j].parts) })
+	}
+	for _, c := range candidates {
+		if c == "" || strings.HasPrefix(c, "//") {
+			// a path starting with "//" would redirect to another host
+			continue
+		}
+		if serves(c) {
+			return c, true
+		}
+		for _, t := range ts {
+			if f, ok := foldPath(t.parts, c); ok && serves(f) {
+				return f, true
+			}
+		}
+	}
+	return "", false
+}
+
+// toggleSlash adds a trailing slash to the path, or removes the one it has.
+func toggleSlash(path string) string {
+	if strings.HasSuffix(path, "/") {
+		return path[:len(path)-1]
+	}
+	return path + "/"
+}
+
+// foldPath matches the path against the pattern made up of the parts,
+// comparing the static parts without regard to case, and returns the path
+// with its static parts spelled the way they are in the pattern.
+func foldPath(parts []part, path string) (string, bool) {
+	if len(parts) == 0 {
+		return "", false
+	}
+	segs := strings.Split(path, "/")
+	for i, pt := range parts {
+		if i == len(segs) {
+			return "", false
+		}
+		switch pt.kind {
+		case radix.Static:
+			if !strings.EqualFold(pt.text, segs[i]) {
+				return "", false
+			}
+			segs[i] = pt.text
+		case radix.CatchAll:
+			return strings.Join(segs, "/"), true
+		default:
+			if !pt.accepts(segs[i]) {
+				return "", false
+			}
+		}
+	}
+	if len(segs) != len(parts) {
+		return "", false
+	}
+	return strings.Join(segs, "/"), true
+}
+
+// redirectTo returns a handler that permanently redirects every request to
+// the path, keeping its query. GET requests are answered with a 301, while
+// every other method is answered with a 308, which tells the client to keep
+// the method and the body of the request.
+func redirectTo(path string) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			u := *r.URL
+			u.Path, u.RawPath = path, ""
+			code := http.StatusPermanentRedirect
+			if r.Method == http.MethodGet {
+				code = http.StatusMovedPermanently
+			}
+			w.Header().Set("Location", u.String())
+			w.WriteHeader(code)
+		},
+	)
+}
@@ -0,0 +1,98 @@
+package netkit
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Jonny-Burkholder/streaming-example/pkg/assert"
+)
+
+// redirectRoutes registers the routes used by the redirect tests.
+func redirectRoutes(r interface {
+	Get(string, http.HandlerFunc, ...RouteOption)
+	Post(string, http.HandlerFunc, ...RouteOption)
+}) {
+	r.Get("/v1/audio", paramsHandler("list"))
+	r.Get("/v1/audio/:id", paramsHandler("track", "id"))
+	r.Get("/users/{id:[0-9]+}", paramsHandler("user", "id"))
+	r.Get("/Docs/Intro", paramsHandler("docs"))
+	r.Get("/static/*filepath", paramsHandler("static", "filepath"))
+	r.Post("/upload", paramsHandler("upload"))
+}
+
+func TestRedirects(t *testing.T) {
+	rm := NewRouter(&Config{
+		LoggingLevel:          LevelOff,
+		RedirectTrailingSlash: true,
+		RedirectFixedPath:     true,
+		CaseInsensitivePaths:  true,
+	})
+	redirectRoutes(rm)
+	rt := NewRouterV2(RedirectTrailingSlash(), RedirectFixedPath(), CaseInsensitivePaths())
+	redirectRoutes(rt)
+
+	tests := []struct {
+		method   string
+		path     string
+		code     int
+		location string
+	}{
+		{"GET", "/v1/audio/42", 200, ""},
+		{"GET", "/v1/audio/", 301, "/v1/audio"},
+		{"GET", "/v1//audio", 301, "/v1/audio"},
+		{"GET", "/v1/./audio/../audio/42?x=1", 301, "/v1/audio/42?x=1"},
+		{"POST", "/upload/", 308, "/upload"},
+		{"GET", "/docs/intro", 301, "/Docs/Intro"},
+		{"GET", "/DOCS/INTRO/", 301, "/Docs/Intro"},
+		{"GET", "/V1/Audio/Track", 301, "/v1/audio/Track"},
+		{"GET", "/STATIC/css/Site.css", 301, "/static/css/Site.css"},
+		{"GET", "/USERS/42", 301, "/users/42"},
+		{"GET", "/USERS/abc", 404, ""},
+		{"POST", "/v1/audio/", 404, ""},
+		{"GET", "//example.com/", 404, ""},
+		{"GET", "/missing", 404, ""},
+	}
+	for _, r := range []http.Handler{rm, rt} {
+		for _, tt := range tests {
+			w := serve(r, tt.method, tt.path)
+			assert.Equal(t, tt.code, w.Code)
+			assert.Equal(t, tt.location, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestRedirects_Off(t *testing.T) {
+	rm := newTestRouter()
+	redirectRoutes(rm)
+	rt := NewRouterV2()
+	redirectRoutes(rt)
+
+	for _, r := range []http.Handler{rm, rt} {
+		for _, path := range []string{"/v1/audio/", "/v1//audio", "/docs/intro"} {
+			assert.Equal(t, http.StatusNotFound, serve(r, "GET", path).Code)
+		}
+	}
+}
+
+func TestRedirects_Only(t *testing.T) {
+	// each option only redirects the paths it is responsible for
+	tests := []struct {
+		rt       *RouterV2
+		path     string
+		code     int
+		location string
+	}{
+		{NewRouterV2(RedirectTrailingSlash()), "/v1/audio/", 301, "/v1/audio"},
+		{NewRouterV2(RedirectTrailingSlash()), "/v1//audio", 404, ""},
+		{NewRouterV2(RedirectFixedPath()), "/v1//audio", 301, "/v1/audio"},
+		{NewRouterV2(RedirectFixedPath()), "/v1/audio/", 404, ""},
+		{NewRouterV2(CaseInsensitivePaths()), "/V1/AUDIO", 301, "/v1/audio"},
+		{NewRouterV2(CaseInsensitivePaths()), "/V1/AUDIO/", 404, ""},
+	}
+	for _, tt := range tests {
+		redirectRoutes(tt.rt)
+		w := serve(tt.rt, "GET", tt.path)
+		assert.Equal(t, tt.code, w.Code)
+		assert.Equal(t, tt.location, w.Header().Get("Location"))
+	}
+}
@@ -4,20 +4,46 @@ import (
 	"fmt"
 	"mime"
 	"net/http"
-	"regexp"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+
+	"github.com/Jonny-Burkholder/streaming-example/pkg/trees/radix"
 )
 
 type routeEntry struct {
 	method  string
 	pattern string
-	regex   *regexp.Regexp
+	name    string
+	group   string
+	params  []string
 	handler http.Handler
+
+	middleware []Middleware
+	matchers   []Matcher
+	doc        *RouteDoc
+	site       string
+	seq        uint64
+	groupChain *groupChain
+	chained    *chained // the handler behind the middleware of the router
+	implicit   bool
+	variant    int // the number of optional parameters left out, see Pattern.variants
+}
+
+// registration describes the entry, and the place it was registered.
+func (m routeEntry) registration() Registration {
+	return Registration{Method: m.method, Pattern: m.pattern, Site: m.site}
 }
 
 func (m routeEntry) String() string {
+	if m.name != "" {
+		return m.link() + fmt.Sprintf("&nbsp;&nbsp;(%s)", m.name)
+	}
+	return m.link()
+}
+
+func (m routeEntry) link() string {
 	if m.method == http.MethodGet {
 		return fmt.Sprintf("[%s]&nbsp;&nbsp;&nbsp;&nbsp;<a href=\"%s\">%s</a>", m.method, m.pattern, m.pattern)
 	}
@@ -41,6 +67,29 @@ type Config struct {
 	ErrHandler    http.Handler
 	MetricsOn     bool
 	LoggingLevel  int
+
+	// RedirectTrailingSlash, RedirectFixedPath and CaseInsensitivePaths
+	// redirect requests whose path does not match any route to the route
+	// that they were most likely meant for, see the RouterV2 options of the
+	// same names.
+	RedirectTrailingSlash bool
+	RedirectFixedPath     bool
+	CaseInsensitivePaths  bool
+
+	// NotFoundHandler, MethodNotAllowedHandler, UnsupportedMediaTypeHandler
+	// and PanicHandler handle the requests that no route serves, and the
+	// requests whose handler panics, see the RouterV2 options of the same
+	// names. Groups can override them.
+	NotFoundHandler             http.Handler
+	MethodNotAllowedHandler     http.Handler
+	UnsupportedMediaTypeHandler http.Handler
+	PanicHandler                func(http.ResponseWriter, *http.Request, any)
+
+	// OpenAPIPath is the path at which the OpenAPI document of the routes
+	// is served, along with the metrics page, see OpenAPIHandler. It is
+	// not served when OpenAPIPath is empty.
+	OpenAPIPath string
+	OpenAPIInfo OpenAPIInfo
 }
 
 var defaultConfig = &Config{
@@ -51,11 +100,74 @@ var defaultConfig = &Config{
 }
 
 type Router struct {
-	lock        sync.Mutex
-	entryMap    map[string]routeEntry
-	entrySet    []routeEntry
+	lock        sync.Mutex // serializes changes to the routes
+	routes      atomic.Pointer[routerTable]
+	names       routeNames
 	logger      *Logger
 	withLogging bool
+	paths       pathPolicy
+	fallbacks   fallbacks
+	groups      map[string]*Group // by prefix, see NewGroup
+}
+
+// routerTable is an immutable snapshot of everything a Router needs to route
+// a request. Every change to the routes is made on a copy of the current table,
+// which is then swapped in, so requests are routed without taking a lock, and
+// a request that is being routed keeps using the table it started out with.
+//
+// The copies share as much as they can with the table they were made from,
+// so registering a route does not copy every other route. The entries are a
+// tree that copies only the nodes it changes, and the lists are never changed
+// in place, they are replaced by the functions that change them.
+type routerTable struct {
+	entries  *radix.Tree    // method tables by shape
+	entrySet []*methodTable // prefix patterns, longest first
+	ordered  []*methodTable // parameterized and prefix patterns, by precedence
+	hosts    hostRoutes
+	chain    *Chain
+	groups   fallbackSet
+}
+
+// clone returns a shallow copy of the table. The method tables are shared
+// with the original until they are copied by own.
+func (rt *routerTable) clone() *routerTable {
+	return &routerTable{
+		entries:  rt.entries.Clone(),
+		entrySet: rt.entrySet,
+		ordered:  rt.ordered,
+		hosts:    append(hostRoutes(nil), rt.hosts...),
+		chain:    rt.chain,
+		groups:   rt.groups,
+	}
+}
+
+// table returns the method table of the shape, if there is one.
+func (rt *routerTable) table(shape string) (*methodTable, bool) {
+	v, ok := rt.entries.Find(shape)
+	if !ok {
+		return nil, false
+	}
+	return v.(*methodTable), true
+}
+
+// tables returns every method table, ordered by shape.
+func (rt *routerTable) tables() []*methodTable {
+	ts := make([]*methodTable, 0, rt.entries.Len())
+	rt.entries.Walk(func(_ string, v any) bool {
+		ts = append(ts, v.(*methodTable))
+		return false
+	})
+	return ts
+}
+
+// own replaces the method table with a copy of it, which may be changed
+// without affecting any other snapshot, and returns the copy.
+func (rt *routerTable) own(t *methodTable) *methodTable {
+	c := t.clone()
+	rt.entries.Insert(t.shape, c)
+	rt.entrySet = replaceTable(rt.entrySet, t, c)
+	rt.ordered = replaceTable(rt.ordered, t, c)
+	return c
 }
 
 func NewRouter(conf *Config) *Router {
@@ -63,10 +175,27 @@ func NewRouter(conf *Config) *Router {
 		conf = defaultConfig
 	}
 	mux := &Router{
-		entryMap: make(map[string]routeEntry),
-		entrySet: make([]routeEntry, 0),
-		logger:   NewLogger(LevelInfo),
+		names:  make(routeNames),
+		groups: make(map[string]*Group),
+		logger: NewLogger(LevelInfo),
+		paths: pathPolicy{
+			trailingSlash: conf.RedirectTrailingSlash,
+			fixedPath:     conf.RedirectFixedPath,
+			caseFold:      conf.CaseInsensitivePaths,
+		},
+		fallbacks: fallbacks{
+			notFound:             conf.NotFoundHandler,
+			methodNotAllowed:     conf.MethodNotAllowedHandler,
+			unsupportedMediaType: conf.UnsupportedMediaTypeHandler,
+			panic:                conf.PanicHandler,
+		},
 	}
+	mux.routes.Store(&routerTable{
+		entries:  radix.NewTree(),
+		entrySet: make([]*methodTable, 0),
+		ordered:  make([]*methodTable, 0),
+		chain:    NewChain(),
+	})
 	if conf.LoggingLevel < LevelOff {
 		mux.logger = NewLogger(conf.LoggingLevel)
 		mux.withLogging = true
@@ -80,88 +209,297 @@ func NewRouter(conf *Config) *Router {
 	if conf.MetricsOn {
 		mux.Handle(http.MethodGet, "/metrics", mux.handleMetrics())
 	}
+	if conf.OpenAPIPath != "" {
+		mux.Handle(http.MethodGet, conf.OpenAPIPath, OpenAPIHandler(mux, conf.OpenAPIInfo))
+	}
 	return mux
 }
 
-// sanitizePattern sanitizes the provided pattern and returns a
-// normalized pattern and a boolean indicating true if it detected
-// that the pattern was a regular expression, and false if it did
-// not detect a regular expression pattern.
-func sanitizePattern(path string) (string, bool) {
-	i := strings.IndexByte(path, '{')
-	j := strings.IndexByte(path, '}')
-	isRegex := false
-	if i > 0 && j > 0 {
-		isRegex = true
-		path = path[:i] + `(?P<` + path[i+1:j] + `>[a-zA-Z0-9]+)`
-	}
-	if path[0] != '^' {
-		path = "^" + path
-	}
-	if path[len(path)-1] != '$' {
-		path = path + "$"
-	}
-	return path, isRegex
+// Handle registers the handler for the given method and pattern. A pattern
+// may be registered once for each method, and Handle panics with a
+// *ConflictError otherwise. A method of "*" answers any
+// method that was not registered explicitly. Patterns
+// ending in a '/' match every path that they are a prefix of. A pattern may
+// also end with a catch-all such as "*filepath" or "{path...}", in which case
+// the rest of the path is captured, and can be read by the handler using Param.
+// Parameters may be constrained with a regular expression, as in "{id:[0-9]+}",
+// or with a type, as in "{id:int}", and trailing parameters may be made
+// optional, as in "/catalog/{page?}". Handle panics if the pattern or one of
+// its constraints is malformed.
+func (rm *Router) Handle(method string, pattern string, handler http.Handler, opts ...RouteOption) {
+	rm.change(func(rt *routerTable) {
+		rm.handle(rt, method, pattern, handler, false, opts)
+	})
+}
+
+// Replace registers the handler for the given method and pattern just like
+// Handle, except that it swaps out the handler that is already registered
+// for them, if there is one, instead of panicking. The swap is atomic, so
+// every request is served by either the old or the new handler, and the
+// requests that are already being served by the old handler finish normally.
+func (rm *Router) Replace(method string, pattern string, handler http.Handler, opts ...RouteOption) {
+	rm.change(func(rt *routerTable) {
+		rm.handle(rt, method, pattern, handler, true, opts)
+	})
 }
 
-func (rm *Router) Handle(method string, pattern string, handler http.Handler) {
+// change applies fn to a copy of the current routes, and swaps the copy in
+// once fn returns. Changes are serialized by rm.lock, and if fn panics, the
+// current routes are left as they were.
+func (rm *Router) change(fn func(rt *routerTable)) {
 	rm.lock.Lock()
 	defer rm.lock.Unlock()
+	rt := rm.routes.Load().clone()
+	fn(rt)
+	rm.routes.Store(rt)
+}
+
+func (rm *Router) handle(rt *routerTable, method string, pattern string, handler http.Handler, replace bool, opts []RouteOption) {
 	if pattern == "" {
 		panic("http: invalid pattern")
 	}
 	if handler == nil {
 		panic("http: nil handler")
 	}
-	var isRegex bool
-	pattern, isRegex = sanitizePattern(pattern)
-	if _, exist := rm.entryMap[pattern]; exist {
-		panic("http: multiple registrations for " + pattern)
+	pat, err := CompilePattern(pattern)
+	if err != nil {
+		panic(err)
 	}
 	entry := routeEntry{
 		method:  method,
 		pattern: pattern,
-		regex:   nil,
+		params:  pat.names,
 		handler: handler,
+		site:    callerSite(),
+		seq:     registrations.Add(1),
 	}
-	if isRegex {
-		re, err := regexp.Compile(pattern)
-		if err != nil {
-			panic(err)
+	applyOptions(&entry, opts)
+	for i, v := range pat.variants() {
+		entry.variant = i
+		rm.handleVariant(rt, v, entry, replace)
+	}
+	if entry.name != "" {
+		rm.names.add(entry.name, pat)
+	}
+}
+
+// handleVariant adds the entry to the table of the variant of its pattern,
+// see handle.
+func (rm *Router) handleVariant(rt *routerTable, pat *Pattern, entry routeEntry, replace bool) {
+	shape := pat.shape()
+	table, exist := rt.table(shape)
+	if exist {
+		table = rt.own(table)
+	} else {
+		table = newMethodTable(pat.raw, pat.names)
+		table.shape = shape
+		table.parts = pat.parts(true)
+		if prefix, isCatchAll := pat.catchAllPrefix(); isCatchAll {
+			table.prefix = prefix
+		} else if pat.raw[len(pat.raw)-1] == '/' && pat.isStatic() && entry.variant == 0 {
+			table.prefix = pat.raw
+		}
+		rt.entries.Insert(shape, table)
+		if table.prefix != "" {
+			rt.entrySet = appendSorted(rt.entrySet, table)
+		}
+		if table.prefix != "" || !pat.isStatic() {
+			rt.ordered = insertOrdered(rt.ordered, table)
 		}
-		entry.regex = re
 	}
-	rm.entryMap[pattern] = entry
-	if pattern[len(pattern)-1] == '/' {
-		rm.entrySet = appendSorted(rm.entrySet, entry)
+	old, exist := table.entry(entry.method, matcherKey(entry.matchers))
+	if exist && !replace {
+		panic(duplicateError(entry, old))
 	}
+	table.add(entry)
+	if exist && old.name != entry.name {
+		rm.names.release(old.name, table)
+	}
+}
+
+// Unregister removes the handler that was registered for the given method
+// and pattern, and reports whether there was one. The requests that are
+// already being served by the handler finish normally.
+func (rm *Router) Unregister(method string, pattern string) bool {
+	pat, err := CompilePattern(pattern)
+	if err != nil {
+		return false
+	}
+	var removed bool
+	rm.change(func(rt *routerTable) {
+		for _, v := range pat.variants() {
+			if table, found := rt.table(v.shape()); found && len(rm.unregister(rt, table, method)) > 0 {
+				removed = true
+			}
+		}
+	})
+	return removed
 }
 
-func (rm *Router) HandleFunc(method, pattern string, handler func(http.ResponseWriter, *http.Request)) {
+// RemoveGroup removes every route that was registered below the prefix of
+// a group, including the routes of any nested groups, and returns the number
+// of routes that were removed. The groups themselves are forgotten, so a later
+// call to NewGroup starts out with a fresh group.
+func (rm *Router) RemoveGroup(prefix string) int {
+	prefix = "/" + strings.Trim(prefix, "/")
+	var n int
+	rm.change(func(rt *routerTable) {
+		for _, table := range rt.tables() {
+			if !inGroup(prefix, table.pattern) {
+				continue
+			}
+			for _, method := range table.methods() {
+				n += countRoutes(rm.unregister(rt, table, method))
+			}
+		}
+		for group := range rm.groups {
+			if inGroup(prefix, group) {
+				delete(rm.groups, group)
+			}
+		}
+		rt.groups = rt.groups.remove(prefix)
+	})
+	return n
+}
+
+// unregister removes the entries for the method from the table, removes the
+// table itself once it is empty, and returns the removed entries.
+func (rm *Router) unregister(rt *routerTable, table *methodTable, method string) []routeEntry {
+	if !table.has(method) {
+		return nil
+	}
+	table = rt.own(table)
+	removed := table.remove(method)
+	for _, old := range removed {
+		rm.names.release(old.name, table)
+	}
+	if table.empty() {
+		rt.entries.Delete(table.shape)
+		rt.entrySet = deleteTable(rt.entrySet, table)
+		rt.ordered = deleteTable(rt.ordered, table)
+	}
+	return removed
+}
+
+// Validate reports every pair of routes that may match the same request, as
+// ConflictErrors, so that overlaps such as "/users/:id" and "/users/new" can
+// be caught when the server starts. Registering the same method and pattern
+// twice is caught by Handle itself. It returns nil if there are no conflicts.
+func (rm *Router) Validate() error {
+	var es []routeEntry
+	for _, table := range rm.routes.Load().tables() {
+		es = append(es, table.sorted()...)
+	}
+	return findConflicts(es, true)
+}
+
+// Use appends middleware to the router. Router middleware wraps every request
+// that the router serves, including those that end up as a 404 or a 405, and
+// it runs before the middleware of groups and routes.
+func (rm *Router) Use(mw ...Middleware) {
+	rm.change(func(rt *routerTable) {
+		rt.chain = rt.chain.Append(mw...)
+	})
+}
+
+// URL builds the path of the route that was registered with the name, by
+// filling in its parameters with the provided key and value pairs, as in
+// rm.URL("audio.track", "id", "42"). The values are URL-escaped, and an error
+// is returned if a value is missing or does not satisfy its constraint.
+func (rm *Router) URL(name string, pairs ...string) (string, error) {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	return rm.names.url(name, pairs)
+}
+
+func (rm *Router) HandleFunc(method, pattern string, handler func(http.ResponseWriter, *http.Request), opts ...RouteOption) {
 	if handler == nil {
 		panic("http: nil handler")
 	}
-	rm.Handle(method, pattern, http.HandlerFunc(handler))
+	rm.Handle(method, pattern, http.HandlerFunc(handler), opts...)
 }
 
 func (rm *Router) Forward(oldpattern string, newpattern string) {
 	rm.Handle(http.MethodGet, oldpattern, http.RedirectHandler(newpattern, http.StatusTemporaryRedirect))
 }
 
-func (rm *Router) Get(pattern string, handler http.HandlerFunc) {
-	rm.HandleFunc(http.MethodGet, pattern, handler)
+func (rm *Router) Get(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	rm.HandleFunc(http.MethodGet, pattern, handler, opts...)
+}
+
+func (rm *Router) Post(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	rm.HandleFunc(http.MethodPost, pattern, handler, opts...)
+}
+
+func (rm *Router) Put(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	rm.HandleFunc(http.MethodPut, pattern, handler, opts...)
+}
+
+func (rm *Router) Delete(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	rm.HandleFunc(http.MethodDelete, pattern, handler, opts...)
+}
+
+func (rm *Router) Patch(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	rm.HandleFunc(http.MethodPatch, pattern, handler, opts...)
+}
+
+func (rm *Router) Head(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	rm.HandleFunc(http.MethodHead, pattern, handler, opts...)
+}
+
+func (rm *Router) Options(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	rm.HandleFunc(http.MethodOptions, pattern, handler, opts...)
+}
+
+// Any registers the handler for every method that does not have a handler
+// of its own registered for the pattern.
+func (rm *Router) Any(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	rm.HandleFunc("*", pattern, handler, opts...)
 }
 
-func (rm *Router) Post(pattern string, handler http.HandlerFunc) {
-	rm.HandleFunc(http.MethodPost, pattern, handler)
+// Host returns a sub-router that serves every request whose host matches
+// the pattern, such as "media.example.com" or "{tenant}.example.com". The
+// host parameters can be read by the handlers using Param, just like path
+// parameters. Requests that do not match any host are served by rm itself.
+// Calling Host again with the same pattern returns the same sub-router.
+func (rm *Router) Host(pattern string) *Router {
+	var sub *Router
+	rm.change(func(rt *routerTable) {
+		if h, found := rt.hosts.find(pattern); found {
+			sub = h.(*Router)
+			return
+		}
+		host, err := parseHost(pattern)
+		if err != nil {
+			panic(err)
+		}
+		sub = NewRouter(&Config{LoggingLevel: LevelOff})
+		sub.paths = rm.paths
+		sub.fallbacks = rm.fallbacks
+		rt.hosts = rt.hosts.insert(newHostRoute(host, sub))
+	})
+	return sub
 }
 
-func (rm *Router) Put(pattern string, handler http.HandlerFunc) {
-	rm.HandleFunc(http.MethodPut, pattern, handler)
+// Mount hands every request below the prefix, along with the prefix itself,
+// to the handler, whatever the method. The prefix is stripped from the path
+// before the handler is called, so another Router, or any other http.Handler,
+// can serve the subtree as if it were the root.
+func (rm *Router) Mount(prefix string, handler http.Handler) {
+	rm.mount(prefix, handler, nil)
 }
 
-func (rm *Router) Delete(pattern string, handler http.HandlerFunc) {
-	rm.HandleFunc(http.MethodDelete, pattern, handler)
+func (rm *Router) mount(prefix string, handler http.Handler, opts []RouteOption) {
+	if handler == nil {
+		panic("http: nil handler")
+	}
+	prefix = mountPrefix(prefix)
+	handler = stripPrefix(prefix, handler)
+	opts = append(opts, withoutParams())
+	if prefix != "" {
+		rm.Handle("*", prefix, handler, opts...)
+	}
+	rm.Handle("*", prefix+"/*path", handler, opts...)
 }
 
 func (rm *Router) Static(pattern string, path string) {
@@ -180,15 +518,17 @@ func (rm *Router) handleMetrics() http.Handler {
 			// Collect base routes (routes that have sub-routes)
 			var base []routeEntry
 			sb.WriteString("<h4>Base routes:</h4>")
-			rm.lock.Lock()
-			for _, entry := range rm.entrySet {
-				base = append(base, entry)
+			rt := rm.routes.Load()
+			for _, table := range rt.entrySet {
+				base = append(base, table.sorted()...)
 			}
-			rm.lock.Unlock()
 			//
 			// Sort and write base routes
-			sort.Slice(base, func(i, j int) bool { return base[i].pattern < base[j].pattern })
+			sort.SliceStable(base, func(i, j int) bool { return base[i].pattern < base[j].pattern })
 			for _, ent := range base {
+				if ent.variant > 0 {
+					continue
+				}
 				sb.WriteString(ent.String())
 				sb.WriteString("<br>")
 			}
@@ -198,19 +538,19 @@ func (rm *Router) handleMetrics() http.Handler {
 			// Collect sub routes (routes that are a continuation of a base route)
 			var sub []routeEntry
 			sb.WriteString("<h4>Sub routes:</h4>")
-			rm.lock.Lock()
-			for _, entry := range rm.entryMap {
-				p := entry.pattern
-				if p[len(p)-1] == '/' {
+			for _, table := range rt.tables() {
+				if table.prefix != "" {
 					continue
 				}
-				sub = append(sub, entry)
+				sub = append(sub, table.sorted()...)
 			}
-			rm.lock.Unlock()
 			//
 			// Sort and write base routes
-			sort.Slice(sub, func(i, j int) bool { return sub[i].pattern < sub[j].pattern })
+			sort.SliceStable(sub, func(i, j int) bool { return sub[i].pattern < sub[j].pattern })
 			for _, ent := range sub {
+				if ent.variant > 0 {
+					continue
+				}
 				sb.WriteString(ent.String())
 				sb.WriteString("<br>")
 			}
@@ -231,172 +571,122 @@ func (rm *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	meth, _, hdlr := rm.match(r.URL.Path)
-	if meth != r.Method && meth != "*" {
-		hdlr = http.HandlerFunc(
-			func(w http.ResponseWriter, r *http.Request) {
-				code := http.StatusMethodNotAllowed
-				http.Error(w, http.StatusText(code), code)
-			},
-		)
-	}
-	if hdlr == nil {
-		hdlr = http.NotFoundHandler()
+	var hdlr http.Handler
+	rt := rm.routes.Load()
+	recovers := false
+	if rt.groups.recovers(rm.fallbacks) {
+		f := rt.groups.resolve(rm.fallbacks, r.URL.Path)
+		recovers = f.panic != nil
+		defer f.recover(w, r)
+	}
+	p := getParams()
+	if sub, req, ok := rt.hosts.match(r, p); ok {
+		hdlr, r = sub.then(rt.chain), req
+	} else if table, vals := rt.match(r.URL.Path, p.vals[:0]); table == nil {
+		hdlr = rt.chain.Then(rm.notFound(rt, r))
+	} else if entry, err := table.find(r); err != nil {
+		hdlr = rt.chain.Then(rt.groups.resolve(rm.fallbacks, r.URL.Path).failed(table, err))
+	} else {
+		p.vals = vals
+		hdlr = entry.chained.then(rt.chain)
+		r = p.request(r, entry.params, vals)
 	}
 	if rm.withLogging {
 		// if logging is configured, then log, otherwise skip
-		hdlr = HandleWithLogging(rm.logger, hdlr)
+		serveWithLogging(rm.logger, hdlr, w, r, recovers)
+	} else {
+		hdlr.ServeHTTP(w, r)
 	}
-	hdlr.ServeHTTP(w, r)
-}
-
-func (rm *Router) _ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if r.RequestURI == "*" {
-		if r.ProtoAtLeast(1, 1) {
-			w.Header().Set("Connection", "close")
-		}
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
-	found, entry := rm.matchV0(r)
-	if found {
-		if entry.method != r.Method && entry.method != "*" {
-			entry.handler.ServeHTTP(w, r)
-			return
-		}
-	}
-	http.NotFound(w, r)
-	return
-	// meth, _, hdlr := rm.match(r.URL.Path)
-	// if meth != r.Method && meth != "*" {
-	// 	hdlr = http.HandlerFunc(
-	// 		func(w http.ResponseWriter, r *http.Request) {
-	// 			code := http.StatusMethodNotAllowed
-	// 			http.Error(w, http.StatusText(code), code)
-	// 		},
-	// 	)
-	// }
-	// if hdlr == nil {
-	// 	hdlr = http.NotFoundHandler()
-	// }
-	// if rm.withLogging {
-	// 	// if logging is configured, then log, otherwise skip
-	// 	hdlr = HandleWithLogging(rm.logger, hdlr)
-	// }
-	// hdlr.ServeHTTP(w, r)
+	p.put()
 }
 
 func (rm *Router) Len() int {
-	return len(rm.entrySet)
+	return len(rm.routes.Load().entrySet)
 }
 
 func (rm *Router) Less(i, j int) bool {
-	return rm.entrySet[i].pattern < rm.entrySet[j].pattern
+	es := rm.routes.Load().entrySet
+	return es[i].pattern < es[j].pattern
 }
 
+// Swap swaps two of the prefix patterns. Only the list of prefix patterns
+// is copied, the rest of the routes are shared with the current snapshot.
 func (rm *Router) Swap(i, j int) {
-	rm.entrySet[j], rm.entrySet[i] = rm.entrySet[i], rm.entrySet[j]
+	rm.change(func(rt *routerTable) {
+		es := append([]*methodTable(nil), rt.entrySet...)
+		es[j], es[i] = es[i], es[j]
+		rt.entrySet = es
+	})
 }
 
 func (rm *Router) Search(x string) int {
+	es := rm.routes.Load().entrySet
 	return sort.Search(
-		len(rm.entrySet), func(i int) bool {
-			return rm.entrySet[i].pattern >= x
+		len(es), func(i int) bool {
+			return es[i].pattern >= x
 		},
 	)
 }
 
-func (rm *Router) entries() []string {
-	rm.lock.Lock()
-	defer rm.lock.Unlock()
-	var entries []string
-	for _, entry := range rm.entryMap {
-		entries = append(entries, fmt.Sprintf("%s %s\n", entry.method, entry.pattern))
-	}
-	for _, entry := range rm.entrySet {
-		entries = append(entries, fmt.Sprintf("%s %s\n", entry.method, entry.pattern))
-	}
-	return entries
-}
-
-// match attempts to locate a handler on a handler map given a path string. The
-// most-specific (longest) pattern wins. It returns the method, pattern and handler.
-func (rm *Router) match(path string) (string, string, http.Handler) {
+// match attempts to locate the method table of a pattern given a path string.
+// Static patterns are matched exactly, and otherwise the parameterized and
+// prefix patterns are tried in order of precedence, see precedes. It returns
+// the table along with any values captured by its parameters, which are
+// appended to vals.
+func (rt *routerTable) match(path string, vals []string) (*methodTable, []string) {
 	// first, check for exact match
-	e, ok := rm.entryMap[path]
-	if ok {
-		return e.method, e.pattern, e.handler
-	}
-	// then, check for longest valid match. mux.entrySet
-	// contains all patterns that end in "/" sorted
-	// from longest to shortest
-	for _, e = range rm.entrySet {
-		// inline check for same prefix has prefix
-		if len(path) >= len(e.pattern) && path[0:len(e.pattern)] == e.pattern {
-			return e.method, e.pattern, e.handler
+	if t, ok := rt.table(path); ok && len(t.params) == 0 {
+		return t, vals
+	}
+	// next, check the rest of the patterns, and collect
+	// the captured values in the order of the parameters
+	for _, t := range rt.ordered {
+		if t.prefix != "" {
+			// inline check for same prefix has prefix
+			if len(path) >= len(t.prefix) && path[0:len(t.prefix)] == t.prefix {
+				if len(t.params) > 0 {
+					return t, append(vals, path[len(t.prefix):])
+				}
+				return t, vals
+			}
+			continue
+		}
+		if vs, ok := matchParts(t.parts, path, vals); ok {
+			return t, vs
 		}
 	}
-	return "", "", nil
+	return nil, vals
 }
 
-func (rm *Router) matchV0(r *http.Request) (bool, *routeEntry) {
-	// check each entry in the map for a match against
-	// the current request.
-	for path, entry := range rm.entryMap {
-		// check for the presence of an opening path key
-		// byte, and if we cannot find one, we will do
-		// a simple path match.
-		beg := strings.IndexByte(path, '{')
-		if beg == -1 {
-			// check for a basic path match, and break
-			return path == r.URL.Path, &entry
+// notFound returns the handler for a request whose path does not match any
+// route, which redirects the request if the path policy allows for it.
+func (rm *Router) notFound(rt *routerTable, r *http.Request) http.Handler {
+	serves := func(path string) bool {
+		if t, _ := rt.match(path, nil); t != nil {
+			e, err := t.find(r)
+			return err == nil && !e.implicit
 		}
-		// otherwise, we found an opening path key
-		// byte, so next, we will check for a closed
-		// path key byte.
-		end := strings.IndexByte(path, '}')
-		if end == -1 {
-			// we have not found a closed path
-			// key byte, which means there must
-			// be an error with p1, so a match
-			// will be impossible at this point,
-			// try next path entry...
-			continue
-		}
-		// next, make certain we have a match
-		if path[:beg] != r.URL.Path[:beg] {
-			// we don't so we continue to the next one
-			continue
-		}
-		// we have found a closed path key byte, so
-		// we should now extract the key from path
-		key := path[beg+1 : end]
-		// and then find the value that maps to our key.
-		i := strings.IndexByte(r.URL.Path[beg:], '/')
-		if i == -1 {
-			r.URL.Query().Add(key, r.URL.Path[beg:])
-		} else {
-			r.URL.Query().Add(key, r.URL.Path[beg:beg+i])
-		}
-		// now return our match, and entry
-		return true, &entry
+		return false
+	}
+	if path, ok := rm.paths.redirect(r.URL.Path, serves, rt.tables); ok {
+		return redirectTo(path)
 	}
-	return false, nil
+	return rt.groups.resolve(rm.fallbacks, r.URL.Path).notFoundHandler()
 }
 
-func appendSorted(es []routeEntry, e routeEntry) []routeEntry {
+// appendSorted returns a copy of es with e inserted before the shorter
+// prefixes. The list may be shared with another snapshot, so it is copied.
+func appendSorted(es []*methodTable, e *methodTable) []*methodTable {
 	n := len(es)
 	i := sort.Search(
 		n, func(i int) bool {
-			return len(es[i].pattern) < len(e.pattern)
+			return len(es[i].prefix) < len(e.prefix)
 		},
 	)
-	if i == n {
-		return append(es, e)
-	}
 	// we now know that i points at where we want to insert
-	es = append(es, routeEntry{}) // try to grow the slice in place, any entry works.
-	copy(es[i+1:], es[i:])        // Move shorter entries down
-	es[i] = e
-	return es
+	c := make([]*methodTable, n+1)
+	copy(c, es[:i])
+	copy(c[i+1:], es[i:]) // Move shorter entries down
+	c[i] = e
+	return c
 }
@@ -5,11 +5,23 @@ import (
 )
 
 type RouterInterface interface {
-	Handle(method string, pattern string, handler http.Handler)
-	HandleFunc(method, pattern string, handler func(http.ResponseWriter, *http.Request))
-	Get(pattern string, handler http.HandlerFunc)
-	Post(pattern string, handler http.HandlerFunc)
-	Put(pattern string, handler http.HandlerFunc)
-	Delete(pattern string, handler http.HandlerFunc)
+	Handle(method string, pattern string, handler http.Handler, opts ...RouteOption)
+	HandleFunc(method, pattern string, handler func(http.ResponseWriter, *http.Request), opts ...RouteOption)
+	Get(pattern string, handler http.HandlerFunc, opts ...RouteOption)
+	Post(pattern string, handler http.HandlerFunc, opts ...RouteOption)
+	Put(pattern string, handler http.HandlerFunc, opts ...RouteOption)
+	Delete(pattern string, handler http.HandlerFunc, opts ...RouteOption)
+	Patch(pattern string, handler http.HandlerFunc, opts ...RouteOption)
+	Head(pattern string, handler http.HandlerFunc, opts ...RouteOption)
+	Options(pattern string, handler http.HandlerFunc, opts ...RouteOption)
+	Any(pattern string, handler http.HandlerFunc, opts ...RouteOption)
+	URL(name string, pairs ...string) (string, error)
+	Use(mw ...Middleware)
+	Mount(prefix string, handler http.Handler)
+	Replace(method string, pattern string, handler http.Handler, opts ...RouteOption)
+	Unregister(method string, pattern string) bool
+	RemoveGroup(prefix string) int
+	Validate() error
+	Routes() []RouteInfo
 	ServeHTTP(w http.ResponseWriter, r *http.Request)
 }
@@ -0,0 +1,406 @@
+package netkit
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/Jonny-Burkholder/streaming-example/pkg/assert"
+)
+
+func newTestRouter() *Router {
+	return NewRouter(&Config{LoggingLevel: LevelOff})
+}
+
+func TestRouter_CatchAll(t *testing.T) {
+	rm := newTestRouter()
+	rm.Get("/ping", paramsHandler("ping"))
+	rm.Get("/static/*filepath", paramsHandler("static", "filepath"))
+	rm.Get("/static/media/{path...}", paramsHandler("media", "path"))
+	rm.Get("/files/", paramsHandler("files"))
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+		body   string
+	}{
+		{http.MethodGet, "/ping", 200, "ping"},
+		{http.MethodGet, "/static/css/site.css", 200, "static filepath=css/site.css"},
+		{http.MethodGet, "/static/", 200, "static filepath="},
+		{http.MethodGet, "/static/media/a/b.mp3", 200, "media path=a/b.mp3"},
+		{http.MethodGet, "/files/a/b", 200, "files"},
+		{http.MethodGet, "/missing", 404, ""},
+		{http.MethodPost, "/ping", 405, ""},
+	}
+	for _, tt := range tests {
+		w := serve(rm, tt.method, tt.path)
+		assert.Equal(t, tt.code, w.Code)
+		if tt.code == 200 {
+			assert.Equal(t, tt.body, w.Body.String())
+		}
+	}
+}
+
+func TestRouter_Params(t *testing.T) {
+	rm := newTestRouter()
+	rm.Get("/v1/audio", paramsHandler("list"))
+	rm.Get("/v1/audio/latest", paramsHandler("latest"))
+	rm.Get("/v1/audio/{id:[0-9]+}", paramsHandler("track", "id"))
+	rm.Get("/v1/audio/{id}/parts/{part}", paramsHandler("part", "id", "part"))
+	rm.Get("/v1/users/:user/audio/{id:[0-9]+}/*rest", paramsHandler("rest", "user", "id", "rest"))
+	rm.Get("/v1/audio/{name}", paramsHandler("name", "name"))
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/v1/audio", 200, "list"},
+		{"/v1/audio/latest", 200, "latest"},
+		{"/v1/audio/42", 200, "track id=42"},
+		{"/v1/audio/intro", 200, "name name=intro"},
+		{"/v1/audio/42/parts/3", 200, "part id=42 part=3"},
+		{"/v1/users/bob/audio/7/a/b", 200, "rest user=bob id=7 rest=a/b"},
+		{"/v1/users/bob/audio/x/a/b", 404, ""},
+		{"/v1/audio/42/parts", 404, ""},
+	}
+	for _, tt := range tests {
+		w := serve(rm, http.MethodGet, tt.path)
+		assert.Equal(t, tt.code, w.Code)
+		if tt.code == 200 {
+			assert.Equal(t, tt.body, w.Body.String())
+		}
+	}
+}
+
+func TestRouter_MethodNotAllowed(t *testing.T) {
+	rm := newTestRouter()
+	rm.Get("/v1/audio/{id}", paramsHandler("get", "id"))
+	rm.Post("/v1/audio/{id}", paramsHandler("post", "id"))
+	rm.Delete("/v1/audio/{id}", paramsHandler("delete", "id"))
+	rm.Handle("*", "/v1/any", paramsHandler("any"))
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+		body   string
+	}{
+		{http.MethodGet, "/v1/audio/1", 200, "get id=1"},
+		{http.MethodPost, "/v1/audio/1", 200, "post id=1"},
+		{http.MethodDelete, "/v1/audio/1", 200, "delete id=1"},
+		{http.MethodPut, "/v1/audio/1", 405, ""},
+		{http.MethodPut, "/v1/any", 200, "any"},
+	}
+	for _, tt := range tests {
+		w := serve(rm, tt.method, tt.path)
+		assert.Equal(t, tt.code, w.Code)
+		if tt.code == 200 {
+			assert.Equal(t, tt.body, w.Body.String())
+		}
+		if tt.code == 405 {
+			assert.Equal(t, "DELETE, GET, HEAD, OPTIONS, POST", w.Header().Get(HeaderAllow))
+		}
+	}
+}
+
+func TestRouter_MultipleRegistrations(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected Handle to panic on a duplicate method and pattern")
+		}
+	}()
+	rm := newTestRouter()
+	rm.Get("/v1/audio", paramsHandler("a"))
+	rm.Get("/v1/audio", paramsHandler("b"))
+}
+
+func TestRouter_HeadAndOptions(t *testing.T) {
+	var _ RouterInterface = (*Router)(nil)
+	var _ RouterGroup = (*Group)(nil)
+
+	rm := newTestRouter()
+	rm.Get("/v1/audio/{id}", paramsHandler("get", "id"))
+	rm.Patch("/v1/audio/{id}", paramsHandler("patch", "id"))
+	rm.Options("/v1/video", paramsHandler("options"))
+	rm.Head("/v1/image", paramsHandler("head"))
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+		body   string
+		allow  string
+	}{
+		{http.MethodPatch, "/v1/audio/1", 200, "patch id=1", ""},
+		{http.MethodHead, "/v1/audio/1", 200, "", ""},
+		{http.MethodOptions, "/v1/audio/1", 204, "", "GET, HEAD, OPTIONS, PATCH"},
+		{http.MethodOptions, "/v1/video", 200, "options", ""},
+		{http.MethodHead, "/v1/image", 200, "head", ""},
+		{http.MethodGet, "/v1/image", 405, "", "HEAD, OPTIONS"},
+	}
+	for _, tt := range tests {
+		w := serve(rm, tt.method, tt.path)
+		assert.Equal(t, tt.code, w.Code)
+		assert.Equal(t, tt.allow, w.Header().Get(HeaderAllow))
+		if tt.code != 405 {
+			assert.Equal(t, tt.body, w.Body.String())
+		}
+	}
+}
+
+func TestUnregister(t *testing.T) {
+	for _, r := range []RouterInterface{newTestRouter(), NewRouterV2()} {
+		r.Get("/audio/:id", paramsHandler("get", "id"), WithName("audio.track"))
+		r.Post("/audio/:id", paramsHandler("post", "id"))
+		r.Get("/audio/{id:[0-9]+}/info", paramsHandler("info", "id"))
+
+		assert.Equal(t, false, r.Unregister(http.MethodPut, "/audio/:id"))
+		assert.Equal(t, false, r.Unregister(http.MethodGet, "/video/:id"))
+
+		assert.Equal(t, true, r.Unregister(http.MethodGet, "/audio/:id"))
+		assert.Equal(t, http.StatusMethodNotAllowed, serve(r, "GET", "/audio/42").Code)
+		assert.Equal(t, "post id=42", serve(r, "POST", "/audio/42").Body.String())
+		if _, err := r.URL("audio.track", "id", "42"); err == nil {
+			t.Errorf("%T: expected the name to be released", r)
+		}
+
+		assert.Equal(t, true, r.Unregister(http.MethodPost, "/audio/:id"))
+		assert.Equal(t, http.StatusNotFound, serve(r, "POST", "/audio/42").Code)
+		assert.Equal(t, "info id=42", serve(r, "GET", "/audio/42/info").Body.String())
+
+		assert.Equal(t, true, r.Unregister(http.MethodGet, "/audio/{id:[0-9]+}/info"))
+		assert.Equal(t, http.StatusNotFound, serve(r, "GET", "/audio/42/info").Code)
+
+		// the pattern can be registered again once it is gone
+		r.Get("/audio/:id", paramsHandler("again", "id"))
+		assert.Equal(t, "again id=42", serve(r, "GET", "/audio/42").Body.String())
+
+		// a name stays in use as long as a route with matchers holds it
+		r.Get("/playlist", paramsHandler("get"), WithName("playlist"))
+		r.Post("/playlist", paramsHandler("post"), WithName("playlist"), WithMatchers(ContentType("application/json")))
+		assert.Equal(t, true, r.Unregister(http.MethodGet, "/playlist"))
+		u, err := r.URL("playlist")
+		assert.Equal(t, "/playlist", u)
+		assert.Equal(t, nil, err)
+	}
+}
+
+func TestRemoveGroup(t *testing.T) {
+	rm := newTestRouter()
+	rm.NewGroup("v1").Get("/audio/:id", paramsHandler("v1", "id"))
+	rm.NewGroup("v1/admin").Get("/users", paramsHandler("admin"))
+	rm.NewGroup("v10").Get("/audio/:id", paramsHandler("v10", "id"))
+
+	rt := NewRouterV2()
+	rt.NewGroup("v1").Get("/audio/:id", paramsHandler("v1", "id"))
+	rt.NewGroup("v1").NewGroup("admin").Get("/users", paramsHandler("admin"))
+	rt.NewGroup("v10").Get("/audio/:id", paramsHandler("v10", "id"))
+
+	for _, r := range []RouterInterface{rm, rt} {
+		assert.Equal(t, 200, serve(r, "GET", "/v1/admin/users").Code)
+		// the roots that Router registers for its groups are not counted
+		assert.Equal(t, 2, r.RemoveGroup("/v1/"))
+		assert.Equal(t, http.StatusNotFound, serve(r, "GET", "/v1/audio/42").Code)
+		assert.Equal(t, http.StatusNotFound, serve(r, "GET", "/v1/admin/users").Code)
+		assert.Equal(t, "v10 id=42", serve(r, "GET", "/v10/audio/42").Body.String())
+		assert.Equal(t, 0, r.RemoveGroup("v1"))
+	}
+}
+
+func TestReplace(t *testing.T) {
+	for _, r := range []RouterInterface{newTestRouter(), NewRouterV2()} {
+		r.Get("/audio/:id", paramsHandler("old", "id"))
+
+		// serve requests while the handler is being swapped
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					body := serve(r, "GET", "/audio/42").Body.String()
+					if body != "old id=42" && body != "new id=42" {
+						t.Errorf("%T: unexpected body %q", r, body)
+						return
+					}
+				}
+			}()
+		}
+		r.Replace(http.MethodGet, "/audio/:id", paramsHandler("new", "id"))
+		wg.Wait()
+		assert.Equal(t, "new id=42", serve(r, "GET", "/audio/42").Body.String())
+
+		// replacing a route that does not exist registers it
+		r.Replace(http.MethodPut, "/audio/:id", paramsHandler("put", "id"))
+		assert.Equal(t, "put id=42", serve(r, "PUT", "/audio/42").Body.String())
+	}
+}
+
+func TestHandle_WhileServing(t *testing.T) {
+	for _, r := range []RouterInterface{newTestRouter(), NewRouterV2()} {
+		r.Get("/audio/:id", paramsHandler("track", "id"))
+
+		// register routes while requests are being routed; run with -race
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 50; i++ {
+				r.Get(fmt.Sprintf("/video/%d/:part", i), paramsHandler("video", "part"))
+				r.Unregister(http.MethodGet, fmt.Sprintf("/video/%d/:part", i-1))
+			}
+		}()
+		for i := 0; i < 200; i++ {
+			assert.Equal(t, "track id=42", serve(r, "GET", "/audio/42").Body.String())
+		}
+		<-done
+		assert.Equal(t, "video part=x", serve(r, "GET", "/video/49/x").Body.String())
+		assert.Equal(t, http.StatusNotFound, serve(r, "GET", "/video/48/x").Code)
+	}
+}
+
+func BenchmarkRouterV2_Parallel(b *testing.B) {
+	rt := NewRouterV2()
+	rt.Get("/v2/audio/:id", func(w http.ResponseWriter, r *http.Request) {})
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/v2/audio/42", nil)
+		for pb.Next() {
+			rt.ServeHTTP(w, r)
+		}
+	})
+}
+
+// discardWriter is a response writer that allocates nothing, so that the
+// allocations of routing can be counted on their own.
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+func (w *discardWriter) WriteString(s string) (int, error) { return len(s), nil }
+
+// allocRouters returns both routers with static and parameterized routes,
+// whose handlers read their parameters without allocating. The routers and
+// one of their groups have middleware, which is only built once.
+func allocRouters() []RouterInterface {
+	read := func(w http.ResponseWriter, r *http.Request) {
+		for _, p := range ParamsFromContext(r.Context()) {
+			io.WriteString(w, p.Value)
+		}
+	}
+	pass := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+		})
+	}
+	var routers []RouterInterface
+	for _, r := range []RouterInterface{newTestRouter(), NewRouterV2()} {
+		r.Use(pass)
+		r.Get("/api/users", read)
+		r.Get("/api/users/:id", read)
+		r.Get("/api/users/{id:[0-9]+}/posts/{post}", read)
+		r.Get("/static/*path", read)
+		r.Get("/audio/:track.:format", read)
+		switch r := r.(type) {
+		case *Router:
+			g := r.NewGroup("v1")
+			g.Use(pass)
+			g.Get("/tracks/:id", read)
+		case *RouterV2:
+			g := r.NewGroup("v1")
+			g.Use(pass)
+			g.Get("/tracks/:id", read)
+		}
+		routers = append(routers, r)
+	}
+	return routers
+}
+
+var allocPaths = []string{
+	"/api/users",
+	"/api/users/bob",
+	"/api/users/42/posts/7",
+	"/static/css/site.css",
+	"/audio/live.at.wembley.mp3",
+	"/v1/tracks/7",
+}
+
+func TestServeHTTP_Allocs(t *testing.T) {
+	if testing.Short() || raceEnabled {
+		t.Skip("skipping malloc count in short mode or with the race detector")
+	}
+	for _, rt := range allocRouters() {
+		w := &discardWriter{header: make(http.Header)}
+		for _, path := range allocPaths {
+			r := httptest.NewRequest("GET", path, nil)
+			allocs := testing.AllocsPerRun(100, func() { rt.ServeHTTP(w, r) })
+			if allocs != 0 {
+				t.Errorf("%T: GET %s: expected no allocations, got %v", rt, path, allocs)
+			}
+		}
+	}
+}
+
+func TestServeHTTP_ParamsReused(t *testing.T) {
+	for _, rt := range []RouterInterface{newTestRouter(), NewRouterV2()} {
+		var kept []Params
+		rt.Get("/audio/:id", func(w http.ResponseWriter, r *http.Request) {
+			kept = append(kept, ParamsFromContext(r.Context()).Clone())
+		})
+		serve(rt, "GET", "/audio/1")
+		serve(rt, "GET", "/audio/2")
+		assert.Equal(t, []Params{{{"id", "1"}}, {{"id", "2"}}}, kept)
+	}
+}
+
+func benchmarkServeHTTP(b *testing.B, rt http.Handler, path string) {
+	w := &discardWriter{header: make(http.Header)}
+	r := httptest.NewRequest("GET", path, nil)
+	if allocs := testing.AllocsPerRun(100, func() { rt.ServeHTTP(w, r) }); allocs != 0 && !raceEnabled {
+		b.Fatalf("GET %s: expected no allocations, got %v", path, allocs)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rt.ServeHTTP(w, r)
+	}
+}
+
+func BenchmarkServeHTTP(b *testing.B) {
+	for _, rt := range allocRouters() {
+		for _, path := range allocPaths {
+			b.Run(fmt.Sprintf("%T%s", rt, path), func(b *testing.B) {
+				benchmarkServeHTTP(b, rt, path)
+			})
+		}
+	}
+}
+
+// BenchmarkHandle registers a route on routers that already hold a few
+// thousand routes, which should not copy the routes that are already there.
+func BenchmarkHandle(b *testing.B) {
+	routers := []RouterInterface{NewRouter(&Config{LoggingLevel: LevelOff}), NewRouterV2()}
+	for _, rt := range routers {
+		for i := 0; i < 5000; i++ {
+			rt.Get(fmt.Sprintf("/api/r%d/items", i), paramsHandler("items"))
+			rt.Get(fmt.Sprintf("/api/r%d/items/:id", i), paramsHandler("item", "id"))
+		}
+		n := 0
+		b.Run(fmt.Sprintf("%T", rt), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				n++
+				rt.Get(fmt.Sprintf("/bench/r%d", n), paramsHandler("bench"))
+			}
+		})
+	}
+}
@@ -2,73 +2,339 @@ package netkit
 
 import (
 	"fmt"
-	"log"
 	"mime"
 	"net/http"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/Jonny-Burkholder/streaming-example/pkg/trees/radix"
 )
 
 type RouterV2 struct {
-	lock   sync.Mutex
-	routes *radix.Tree
-	groups []string
+	lock      sync.Mutex // serializes changes to the routes
+	routes    atomic.Pointer[routerV2Table]
+	groups    map[string]*RouterV2Group
+	names     routeNames
+	paths     pathPolicy
+	fallbacks fallbacks
 }
 
-func NewRouterV2() *RouterV2 {
-	return &RouterV2{
-		routes: radix.NewTree(),
-		groups: make([]string, 0),
+// routerV2Table is an immutable snapshot of everything a RouterV2 needs to
+// route a request, see routerTable.
+type routerV2Table struct {
+	tree   *radix.Tree
+	hosts  hostRoutes
+	chain  *Chain
+	groups fallbackSet
+}
+
+// clone returns a copy of the table. The method tables stored in the tree
+// are shared with the original, so they have to be copied before they are
+// changed.
+func (tbl *routerV2Table) clone() *routerV2Table {
+	return &routerV2Table{
+		tree:   tbl.tree.Clone(),
+		hosts:  append(hostRoutes(nil), tbl.hosts...),
+		chain:  tbl.chain,
+		groups: tbl.groups,
 	}
 }
 
-func urlMapping(r rune) rune {
-	switch {
-	case 'a' <= r && r <= 'z':
-		return r - 'a' - 'A'
+// NewRouterV2 returns an empty RouterV2, configured by the options.
+func NewRouterV2(opts ...RouterV2Option) *RouterV2 {
+	rt := &RouterV2{
+		groups: make(map[string]*RouterV2Group),
+		names:  make(routeNames),
+	}
+	rt.routes.Store(&routerV2Table{
+		tree:  radix.NewTree(),
+		chain: NewChain(),
+	})
+	for _, opt := range opts {
+		if opt != nil {
+			opt(rt)
+		}
 	}
-	return r
+	return rt
 }
 
-func sanitize(method string, pattern string) string {
-	return method + pattern //strings.Map(urlMapping, pattern)
+// Handle registers the handler for the given method and pattern. The
+// pattern may contain named parameters such as ":id" or "{id}", and it
+// may end with a catch-all such as "*filepath" or "{path...}", all of
+// which are matched inside the radix tree. Trailing parameters may be made
+// optional, as in "/catalog/{page?}", see Pattern. The captured values can
+// be read by the handler using Param. Each pattern keeps a table of the
+// methods registered for it, so a single path may carry handlers for
+// several methods. Handle panics with a *ConflictError if a handler is
+// already registered for the method and pattern, see Replace.
+func (rt *RouterV2) Handle(method string, pattern string, handler http.Handler, opts ...RouteOption) {
+	rt.change(func(tbl *routerV2Table) {
+		rt.handle(tbl, method, pattern, handler, false, opts)
+	})
 }
 
-func (rt *RouterV2) Handle(method string, pattern string, handler http.Handler) {
+// Replace registers the handler for the given method and pattern, and swaps
+// out the handler that is already registered for them, if there is one. The
+// swap is atomic, so every request is served by either the old or the new
+// handler, and the requests that are already being served by the old handler
+// finish normally.
+func (rt *RouterV2) Replace(method string, pattern string, handler http.Handler, opts ...RouteOption) {
+	rt.change(func(tbl *routerV2Table) {
+		rt.handle(tbl, method, pattern, handler, true, opts)
+	})
+}
+
+// change applies fn to a copy of the current routes, and swaps the copy in
+// once fn returns. Changes are serialized by rt.lock, and if fn panics, the
+// current routes are left as they were.
+func (rt *RouterV2) change(fn func(tbl *routerV2Table)) {
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	tbl := rt.routes.Load().clone()
+	fn(tbl)
+	rt.routes.Store(tbl)
+}
+
+func (rt *RouterV2) handle(tbl *routerV2Table, method string, pattern string, handler http.Handler, replace bool, opts []RouteOption) {
+	if handler == nil {
+		panic("http: nil handler")
+	}
+	pat, err := CompilePattern(pattern)
+	if err != nil {
+		panic(err)
+	}
 	entry := routeEntry{
 		method:  method,
 		pattern: pattern,
+		params:  pat.names,
 		handler: handler,
+		site:    callerSite(),
+		seq:     registrations.Add(1),
+	}
+	applyOptions(&entry, opts)
+	for i, v := range pat.variants() {
+		entry.variant = i
+		rt.handleVariant(tbl, v, entry, replace)
+	}
+	if entry.name != "" {
+		rt.names.add(entry.name, pat)
 	}
-	rt.routes.Insert(sanitize(method, pattern), entry)
 }
 
-func (rt *RouterV2) HandleFunc(method, pattern string, handler func(http.ResponseWriter, *http.Request)) {
-	entry := routeEntry{
-		method:  method,
-		pattern: pattern,
-		handler: http.HandlerFunc(handler),
+// handleVariant adds the entry to the table of the variant of its pattern,
+// see handle.
+func (rt *RouterV2) handleVariant(tbl *routerV2Table, pat *Pattern, entry routeEntry, replace bool) {
+	var table *methodTable
+	if key, v, found := tbl.tree.FindRoute(pat.segs); found {
+		table = v.(*methodTable).clone()
+		tbl.tree.InsertRoute(key, pat.segs, table)
+	} else {
+		table = newMethodTable(pat.raw, pat.names)
+		table.shape = pat.shape()
+		table.parts = pat.parts(false)
+		tbl.tree.InsertRoute(pat.raw, pat.segs, table)
+	}
+	old, exist := table.entry(entry.method, matcherKey(entry.matchers))
+	if exist && !replace {
+		panic(duplicateError(entry, old))
+	}
+	table.add(entry)
+	if exist && old.name != entry.name {
+		rt.names.release(old.name, table)
+	}
+}
+
+// Unregister removes the handler that was registered for the given method
+// and pattern, and reports whether there was one. The requests that are
+// already being served by the handler finish normally.
+func (rt *RouterV2) Unregister(method string, pattern string) bool {
+	pat, err := CompilePattern(pattern)
+	if err != nil {
+		return false
+	}
+	var removed bool
+	rt.change(func(tbl *routerV2Table) {
+		for _, v := range pat.variants() {
+			if len(rt.unregister(tbl, v, method)) > 0 {
+				removed = true
+			}
+		}
+	})
+	return removed
+}
+
+// RemoveGroup removes every route that was registered below the prefix of
+// a group, including the routes of any nested groups, and returns the number
+// of routes that were removed. The groups themselves are forgotten, so a later
+// call to NewGroup starts out with a fresh group.
+func (rt *RouterV2) RemoveGroup(prefix string) int {
+	prefix = "/" + strings.Trim(prefix, "/")
+	var n int
+	rt.change(func(tbl *routerV2Table) {
+		var tables []*methodTable
+		tbl.tree.Walk(func(k string, v any) bool {
+			if inGroup(prefix, k) {
+				tables = append(tables, v.(*methodTable))
+			}
+			return false
+		})
+		for _, table := range tables {
+			pat, _ := CompilePattern(table.pattern)
+			for _, method := range table.methods() {
+				n += countRoutes(rt.unregister(tbl, pat, method))
+			}
+		}
+		for group := range rt.groups {
+			if inGroup(prefix, strings.TrimSuffix(group, "/")) {
+				delete(rt.groups, group)
+			}
+		}
+		tbl.groups = tbl.groups.remove(prefix)
+	})
+	return n
+}
+
+// unregister removes the entries for the method from the table, removes the
+// table from the tree once it is empty, and returns the removed entries, see
+// Router.unregister.
+func (rt *RouterV2) unregister(tbl *routerV2Table, pat *Pattern, method string) []routeEntry {
+	key, v, found := tbl.tree.FindRoute(pat.segs)
+	if !found {
+		return nil
+	}
+	table := v.(*methodTable)
+	if !table.has(method) {
+		return nil
+	}
+	table = table.clone()
+	removed := table.remove(method)
+	for _, old := range removed {
+		rt.names.release(old.name, table)
+	}
+	if table.empty() {
+		tbl.tree.DeleteRoute(pat.segs)
+	} else {
+		tbl.tree.InsertRoute(key, pat.segs, table)
+	}
+	return removed
+}
+
+// Validate reports every pair of routes that may match the same request, as
+// ConflictErrors, see Router.Validate. It returns nil if there are no conflicts.
+func (rt *RouterV2) Validate() error {
+	var es []routeEntry
+	rt.routes.Load().tree.Walk(func(k string, v any) bool {
+		es = append(es, v.(*methodTable).sorted()...)
+		return false
+	})
+	return findConflicts(es, false)
+}
+
+// Use appends middleware to the router. Router middleware wraps every request
+// that the router serves, including those that end up as a 404 or a 405, and
+// it runs before the middleware of groups and routes.
+func (rt *RouterV2) Use(mw ...Middleware) {
+	rt.change(func(tbl *routerV2Table) {
+		tbl.chain = tbl.chain.Append(mw...)
+	})
+}
+
+// URL builds the path of the route that was registered with the name, by
+// filling in its parameters with the provided key and value pairs, as in
+// rt.URL("audio.track", "id", "42"). The values are URL-escaped, and an error
+// is returned if a value is missing or does not satisfy its constraint.
+func (rt *RouterV2) URL(name string, pairs ...string) (string, error) {
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	return rt.names.url(name, pairs)
+}
+
+func (rt *RouterV2) HandleFunc(method, pattern string, handler func(http.ResponseWriter, *http.Request), opts ...RouteOption) {
+	if handler == nil {
+		panic("http: nil handler")
 	}
-	rt.routes.Insert(sanitize(method, pattern), entry)
+	rt.Handle(method, pattern, http.HandlerFunc(handler), opts...)
+}
+
+func (rt *RouterV2) Get(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	rt.HandleFunc(http.MethodGet, pattern, handler, opts...)
+}
+
+func (rt *RouterV2) Post(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	rt.HandleFunc(http.MethodPost, pattern, handler, opts...)
+}
+
+func (rt *RouterV2) Put(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	rt.HandleFunc(http.MethodPut, pattern, handler, opts...)
+}
+
+func (rt *RouterV2) Delete(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	rt.HandleFunc(http.MethodDelete, pattern, handler, opts...)
 }
 
-func (rt *RouterV2) Get(pattern string, handler http.HandlerFunc) {
-	rt.HandleFunc(http.MethodGet, pattern, handler)
+func (rt *RouterV2) Patch(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	rt.HandleFunc(http.MethodPatch, pattern, handler, opts...)
 }
 
-func (rt *RouterV2) Post(pattern string, handler http.HandlerFunc) {
-	rt.HandleFunc(http.MethodPost, pattern, handler)
+func (rt *RouterV2) Head(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	rt.HandleFunc(http.MethodHead, pattern, handler, opts...)
 }
 
-func (rt *RouterV2) Put(pattern string, handler http.HandlerFunc) {
-	rt.HandleFunc(http.MethodPut, pattern, handler)
+func (rt *RouterV2) Options(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	rt.HandleFunc(http.MethodOptions, pattern, handler, opts...)
 }
 
-func (rt *RouterV2) Delete(pattern string, handler http.HandlerFunc) {
-	rt.HandleFunc(http.MethodDelete, pattern, handler)
+// Any registers the handler for every method that does not have a handler
+// of its own registered for the pattern.
+func (rt *RouterV2) Any(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	rt.HandleFunc("*", pattern, handler, opts...)
+}
+
+// Host returns a sub-router that serves every request whose host matches
+// the pattern, such as "media.example.com" or "{tenant}.example.com". The
+// host parameters can be read by the handlers using Param, just like path
+// parameters. Requests that do not match any host are served by rt itself.
+// Calling Host again with the same pattern returns the same sub-router.
+func (rt *RouterV2) Host(pattern string) *RouterV2 {
+	var sub *RouterV2
+	rt.change(func(tbl *routerV2Table) {
+		if h, found := tbl.hosts.find(pattern); found {
+			sub = h.(*RouterV2)
+			return
+		}
+		host, err := parseHost(pattern)
+		if err != nil {
+			panic(err)
+		}
+		sub = NewRouterV2()
+		sub.paths = rt.paths
+		sub.fallbacks = rt.fallbacks
+		tbl.hosts = tbl.hosts.insert(newHostRoute(host, sub))
+	})
+	return sub
+}
+
+// Mount hands every request below the prefix, along with the prefix itself,
+// to the handler, whatever the method. The prefix is stripped from the path
+// before the handler is called, so another RouterV2, or any other http.Handler,
+// can serve the subtree as if it were the root.
+func (rt *RouterV2) Mount(prefix string, handler http.Handler) {
+	rt.mount(prefix, handler, nil)
+}
+
+func (rt *RouterV2) mount(prefix string, handler http.Handler, opts []RouteOption) {
+	if handler == nil {
+		panic("http: nil handler")
+	}
+	prefix = mountPrefix(prefix)
+	handler = stripPrefix(prefix, handler)
+	opts = append(opts, withoutParams())
+	if prefix != "" {
+		rt.Handle("*", prefix, handler, opts...)
+	}
+	rt.Handle("*", prefix+"/*path", handler, opts...)
 }
 
 func (rt *RouterV2) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -79,14 +345,61 @@ func (rt *RouterV2) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	matched, entry, found := rt.routes.FindLongestPrefix(sanitize(r.Method, r.URL.Path))
+	tbl := rt.routes.Load()
+	if tbl.groups.recovers(rt.fallbacks) {
+		defer tbl.groups.resolve(rt.fallbacks, r.URL.Path).recover(w, r)
+	}
+	p := getParams()
+	hdlr, r := rt.handler(tbl, r, p)
+	hdlr.ServeHTTP(w, r)
+	p.put()
+}
+
+// handler returns the handler that should serve the request, behind the
+// middleware of the router, along with the request carrying any parameters
+// that were captured for it, which is backed by p. Requests that do not
+// match any route are redirected if the path policy allows it.
+func (rt *RouterV2) handler(tbl *routerV2Table, r *http.Request, p *routeParams) (http.Handler, *http.Request) {
+	if sub, req, ok := tbl.hosts.match(r, p); ok {
+		return sub.then(tbl.chain), req
+	}
+	_, v, vals, found := tbl.tree.Lookup(r.URL.Path, p.vals[:0])
 	if !found {
-		http.NotFound(w, r)
-		return
+		return tbl.chain.Then(rt.notFound(tbl, r)), r
+	}
+	p.vals = vals
+	table := v.(*methodTable)
+	entry, err := table.find(r)
+	if err != nil {
+		return tbl.chain.Then(tbl.groups.resolve(rt.fallbacks, r.URL.Path).failed(table, err)), r
 	}
-	log.Printf("path: %q, matched: %q\n", r.URL.Path, matched)
-	entry.(routeEntry).handler.ServeHTTP(w, r)
-	return
+	return entry.chained.then(tbl.chain), p.request(r, entry.params, vals)
+}
+
+// notFound returns the handler for a request whose path does not match any
+// route, see Router.notFound.
+func (rt *RouterV2) notFound(tbl *routerV2Table, r *http.Request) http.Handler {
+	serves := func(path string) bool {
+		if _, v, _, found := tbl.tree.Lookup(path, nil); found {
+			_, err := v.(*methodTable).find(r)
+			return err == nil
+		}
+		return false
+	}
+	tables := func() []*methodTable {
+		var ts []*methodTable
+		tbl.tree.Walk(func(_ string, v any) bool {
+			if t, ok := v.(*methodTable); ok {
+				ts = append(ts, t)
+			}
+			return false
+		})
+		return ts
+	}
+	if path, ok := rt.paths.redirect(r.URL.Path, serves, tables); ok {
+		return redirectTo(path)
+	}
+	return tbl.groups.resolve(rt.fallbacks, r.URL.Path).notFoundHandler()
 }
 
 func (rt *RouterV2) MetricsHandler(w http.ResponseWriter, r *http.Request) {
@@ -97,15 +410,18 @@ func (rt *RouterV2) MetricsHandler(w http.ResponseWriter, r *http.Request) {
 	//
 	// Walk all routes
 	sb.WriteString("<h4>Routes:</h4>")
-	rt.lock.Lock()
-	rt.routes.Walk(func(k string, v any) bool {
-		if ent, castOkay := v.(routeEntry); castOkay {
-			sb.WriteString(ent.String())
-			sb.WriteString("<br>")
+	rt.routes.Load().tree.Walk(func(k string, v any) bool {
+		if table, castOkay := v.(*methodTable); castOkay {
+			for _, ent := range table.sorted() {
+				if ent.variant > 0 {
+					continue
+				}
+				sb.WriteString(ent.String())
+				sb.WriteString("<br>")
+			}
 		}
 		return false
 	})
-	rt.lock.Unlock()
 	//
 	// Write Content-Type header, and write everything to the http.ResponseWriter
 	w.Header().Set("Content-Type", mime.TypeByExtension(".html"))
@@ -113,23 +429,102 @@ func (rt *RouterV2) MetricsHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "%s", sb)
 }
 
+// NewGroup returns a group of routes that share the prefix. Calling NewGroup
+// again with the same prefix returns the same group.
 func (rt *RouterV2) NewGroup(group string) *RouterV2Group {
+	return rt.newGroup(group, nil)
+}
+
+// newGroup returns the group for the prefix, and creates it nested inside
+// the group with the parent chain if it does not exist yet.
+func (rt *RouterV2) newGroup(group string, parent *groupChain) *RouterV2Group {
 	if len(group) > 0 && group[0] != '/' {
 		group = "/" + group
 	}
 	if group[len(group)-1] != '/' {
 		group += "/"
 	}
-	rt.groups = append(rt.groups, group)
-	return &RouterV2Group{
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	if rg, found := rt.groups[group]; found {
+		return rg
+	}
+	rg := &RouterV2Group{
 		prefix: group,
 		router: rt,
+		chain:  newGroupChain(parent),
 	}
+	rt.groups[group] = rg
+	return rg
 }
 
 type RouterV2Group struct {
 	prefix string
 	router *RouterV2
+	chain  *groupChain
+}
+
+// Use appends middleware to the group, see Group.Use.
+func (rg *RouterV2Group) Use(mw ...Middleware) {
+	rg.router.lock.Lock()
+	defer rg.router.lock.Unlock()
+	rg.chain.use(mw...)
+}
+
+// NewGroup returns a group nested inside rg, so that rg.NewGroup("admin")
+// on the group "/v1/" shares the prefix "/v1/admin/". The middleware of rg
+// runs in front of the middleware of the nested group.
+func (rg *RouterV2Group) NewGroup(group string) *RouterV2Group {
+	return rg.router.newGroup(joinGroup(rg.prefix, group), rg.chain)
+}
+
+// Replace swaps in the handler for the method and pattern of the group,
+// see RouterV2.Replace.
+func (rg *RouterV2Group) Replace(method string, pattern string, handler http.Handler, opts ...RouteOption) {
+	rg.router.Replace(method, joinGroup(rg.prefix, pattern), stripGroup(rg.prefix, handler), groupOptions(rg.prefix, rg.chain, opts)...)
+}
+
+// Unregister removes the handler for the method and pattern of the group,
+// see RouterV2.Unregister.
+func (rg *RouterV2Group) Unregister(method string, pattern string) bool {
+	return rg.router.Unregister(method, joinGroup(rg.prefix, pattern))
+}
+
+// Mount hands every request below the prefix of the group joined with
+// the prefix to the handler, see RouterV2.Mount.
+func (rg *RouterV2Group) Mount(prefix string, handler http.Handler) {
+	rg.router.mount(joinGroup(rg.prefix, prefix), handler, groupOptions(rg.prefix, rg.chain, nil))
+}
+
+// NotFound sets the handler for the requests below the prefix of the group
+// whose path does not match any route, in place of the NotFoundHandler of
+// the router. Nested groups use it unless they set one of their own.
+func (rg *RouterV2Group) NotFound(h http.Handler) {
+	rg.setFallback(func(f *fallbacks) { f.notFound = h })
+}
+
+// MethodNotAllowed sets the handler for the requests below the prefix of
+// the group whose method is not allowed, see NotFound.
+func (rg *RouterV2Group) MethodNotAllowed(h http.Handler) {
+	rg.setFallback(func(f *fallbacks) { f.methodNotAllowed = h })
+}
+
+// UnsupportedMediaType sets the handler for the requests below the prefix
+// of the group whose content type no route accepts, see NotFound.
+func (rg *RouterV2Group) UnsupportedMediaType(h http.Handler) {
+	rg.setFallback(func(f *fallbacks) { f.unsupportedMediaType = h })
+}
+
+// OnPanic sets the function that is called when the handler of a request
+// below the prefix of the group panics, see NotFound.
+func (rg *RouterV2Group) OnPanic(fn func(http.ResponseWriter, *http.Request, any)) {
+	rg.setFallback(func(f *fallbacks) { f.panic = fn })
+}
+
+func (rg *RouterV2Group) setFallback(fn func(*fallbacks)) {
+	rg.router.change(func(tbl *routerV2Table) {
+		tbl.groups = tbl.groups.set(rg.prefix, fn)
+	})
 }
 
 // joinGroup cleans and joins the group path with the pattern and
@@ -142,26 +537,47 @@ func joinGroup(group, pattern string) string {
 	return s
 }
 
-func (rg *RouterV2Group) Handle(method string, pattern string, handler http.Handler) {
-	rg.router.Handle(method, joinGroup(rg.prefix, pattern), http.StripPrefix(rg.prefix, handler))
+func (rg *RouterV2Group) Handle(method string, pattern string, handler http.Handler, opts ...RouteOption) {
+	rg.router.Handle(method, joinGroup(rg.prefix, pattern), stripGroup(rg.prefix, handler), groupOptions(rg.prefix, rg.chain, opts)...)
+}
+
+func (rg *RouterV2Group) HandleFunc(method, pattern string, handler func(http.ResponseWriter, *http.Request), opts ...RouteOption) {
+	rg.router.Handle(method, joinGroup(rg.prefix, pattern), stripGroup(rg.prefix, http.HandlerFunc(handler)), groupOptions(rg.prefix, rg.chain, opts)...)
+}
+
+func (rg *RouterV2Group) Get(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	rg.router.Handle(http.MethodGet, joinGroup(rg.prefix, pattern), stripGroup(rg.prefix, handler), groupOptions(rg.prefix, rg.chain, opts)...)
+}
+
+func (rg *RouterV2Group) Post(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	rg.router.Handle(http.MethodPost, joinGroup(rg.prefix, pattern), stripGroup(rg.prefix, handler), groupOptions(rg.prefix, rg.chain, opts)...)
+}
+
+func (rg *RouterV2Group) Put(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	rg.router.Handle(http.MethodPut, joinGroup(rg.prefix, pattern), stripGroup(rg.prefix, handler), groupOptions(rg.prefix, rg.chain, opts)...)
+}
+
+func (rg *RouterV2Group) Delete(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	rg.router.Handle(http.MethodDelete, joinGroup(rg.prefix, pattern), stripGroup(rg.prefix, handler), groupOptions(rg.prefix, rg.chain, opts)...)
 }
 
-func (rg *RouterV2Group) HandleFunc(method, pattern string, handler func(http.ResponseWriter, *http.Request)) {
-	rg.router.Handle(method, joinGroup(rg.prefix, pattern), http.StripPrefix(rg.prefix, http.HandlerFunc(handler)))
+func (rg *RouterV2Group) Patch(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	rg.router.Handle(http.MethodPatch, joinGroup(rg.prefix, pattern), stripGroup(rg.prefix, handler), groupOptions(rg.prefix, rg.chain, opts)...)
 }
 
-func (rg *RouterV2Group) Get(pattern string, handler http.HandlerFunc) {
-	rg.router.Handle(http.MethodGet, joinGroup(rg.prefix, pattern), http.StripPrefix(rg.prefix, handler))
+func (rg *RouterV2Group) Head(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	rg.router.Handle(http.MethodHead, joinGroup(rg.prefix, pattern), stripGroup(rg.prefix, handler), groupOptions(rg.prefix, rg.chain, opts)...)
 }
 
-func (rg *RouterV2Group) Post(pattern string, handler http.HandlerFunc) {
-	rg.router.Handle(http.MethodPost, joinGroup(rg.prefix, pattern), http.StripPrefix(rg.prefix, handler))
+func (rg *RouterV2Group) Options(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	rg.router.Handle(http.MethodOptions, joinGroup(rg.prefix, pattern), stripGroup(rg.prefix, handler), groupOptions(rg.prefix, rg.chain, opts)...)
 }
 
-func (rg *RouterV2Group) Put(pattern string, handler http.HandlerFunc) {
-	rg.router.Handle(http.MethodPut, joinGroup(rg.prefix, pattern), http.StripPrefix(rg.prefix, handler))
+func (rg *RouterV2Group) Any(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	rg.router.Handle("*", joinGroup(rg.prefix, pattern), stripGroup(rg.prefix, handler), groupOptions(rg.prefix, rg.chain, opts)...)
 }
 
-func (rg *RouterV2Group) Delete(pattern string, handler http.HandlerFunc) {
-	rg.router.Handle(http.MethodDelete, joinGroup(rg.prefix, pattern), http.StripPrefix(rg.prefix, handler))
+// URL builds the path of a named route, see RouterV2.URL.
+func (rg *RouterV2Group) URL(name string, pairs ...string) (string, error) {
+	return rg.router.URL(name, pairs...)
 }
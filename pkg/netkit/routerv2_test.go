@@ -0,0 +1,244 @@
+package netkit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Jonny-Burkholder/streaming-example/pkg/assert"
+)
+
+// paramsHandler writes the route name followed by the parameters
+// listed in keys, so tests can check which route was selected.
+func paramsHandler(name string, keys ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, name)
+		for _, k := range keys {
+			fmt.Fprintf(w, " %s=%s", k, Param(r, k))
+		}
+	}
+}
+
+func serve(h http.Handler, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, path, nil)
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestRouterV2_Params(t *testing.T) {
+	rt := NewRouterV2()
+	rt.Get("/v2/audio", paramsHandler("list"))
+	rt.Get("/v2/audio/:id", paramsHandler("track", "id"))
+	rt.Get("/v2/audio/{id}/info", paramsHandler("info", "id"))
+	rt.Get("/v2/audio/:id/parts/:part", paramsHandler("part", "id", "part"))
+	rt.Get("/v2/audio/latest", paramsHandler("latest"))
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/v2/audio", 200, "list"},
+		{"/v2/audio/42", 200, "track id=42"},
+		{"/v2/audio/42/info", 200, "info id=42"},
+		{"/v2/audio/42/parts/3", 200, "part id=42 part=3"},
+		{"/v2/audio/latest", 200, "latest"},
+	}
+	for _, tt := range tests {
+		w := serve(rt, http.MethodGet, tt.path)
+		assert.Equal(t, tt.code, w.Code)
+		if tt.code == 200 {
+			assert.Equal(t, tt.body, w.Body.String())
+		}
+	}
+}
+
+func TestCompilePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		names   []string
+		valid   bool
+	}{
+		{"/audio", nil, true},
+		{"/audio/:id", []string{"id"}, true},
+		{"/audio/{id}", []string{"id"}, true},
+		{"/audio/:id/parts/{part_2}", []string{"id", "part_2"}, true},
+		{"", nil, false},
+		{"/audio/:", nil, false},
+		{"/audio/{}", nil, false},
+		{"/audio/{id", nil, false},
+		{"/audio/{id}x", []string{"id"}, true},
+		{"/audio/x:id", []string{"id"}, true},
+		{"/audio/:track.:format", []string{"track", "format"}, true},
+		{"/image/{name}@{scale:[0-9]x}.png", []string{"name", "scale"}, true},
+		{"/audio/:a:b", nil, false},
+		{"/audio/{a}{b}", nil, false},
+		{"/audio/:id.:id", nil, false},
+		{"/audio/x*rest", nil, false},
+		{"/audio/{rest...}.mp3", nil, false},
+		{"/audio/:id/:id", nil, false},
+		{"/static/*filepath", []string{"filepath"}, true},
+		{"/media/{path...}", []string{"path"}, true},
+		{"/static/*filepath/x", nil, false},
+		{"/media/{path...}/x", nil, false},
+		{"/static/*", nil, false},
+		{"/audio/{id:[0-9]+}", []string{"id"}, true},
+		{"/audio/{id:[0-9]{3}}/{slug:[a-z0-9-]+}", []string{"id", "slug"}, true},
+		{"/audio/{id:}", nil, false},
+		{"/audio/{id:[0-9}", nil, false},
+		{"/audio/{id:(}", nil, false},
+		{"/audio/:i-d", nil, false},
+		{"/audio/:id~", nil, false},
+		{"/audio/{i}-d", []string{"i"}, true},
+		{"/range/{from}-{to}", []string{"from", "to"}, true},
+		{"/image/:name@:scale.png", []string{"name", "scale"}, true},
+		{"/v2/audio/:id/:quality?", []string{"id", "quality"}, true},
+		{"/catalog/{page?}", []string{"page"}, true},
+		{"/catalog/{page?:int}/{size?}", []string{"page", "size"}, true},
+		{"/catalog/{page:int?}", nil, false},
+		{"/catalog/{page?:uuid?}", nil, false},
+		{"/{page?}", []string{"page"}, true},
+		{"/catalog/{page?}/list", nil, false},
+		{"/catalog/{page?}/", nil, false},
+		{"/catalog/{page?}/:size", nil, false},
+		{"/catalog/{page?}/*rest", nil, false},
+		{"/catalog/{rest...?}", nil, false},
+		{"/catalog/p{page?}", nil, false},
+		{"/catalog/{page?}.json", nil, false},
+	}
+	for _, tt := range tests {
+		pat, err := CompilePattern(tt.pattern)
+		if !tt.valid {
+			if err == nil {
+				t.Errorf("CompilePattern(%q): expected an error", tt.pattern)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("CompilePattern(%q): unexpected error: %s", tt.pattern, err)
+			continue
+		}
+		assert.Equal(t, tt.names, pat.names)
+	}
+}
+
+func TestRouterV2_CatchAll(t *testing.T) {
+	rt := NewRouterV2()
+	rt.Get("/static/*filepath", paramsHandler("static", "filepath"))
+	rt.Get("/static/index.html", paramsHandler("index"))
+	rt.Get("/media/:kind/{path...}", paramsHandler("media", "kind", "path"))
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/static/index.html", 200, "index"},
+		{"/static/css/site.css", 200, "static filepath=css/site.css"},
+		{"/static/", 200, "static filepath="},
+		{"/media/audio/a/b.mp3", 200, "media kind=audio path=a/b.mp3"},
+		{"/media/audio", 404, ""},
+		{"/static", 404, ""},
+	}
+	for _, tt := range tests {
+		w := serve(rt, http.MethodGet, tt.path)
+		assert.Equal(t, tt.code, w.Code)
+		if tt.code == 200 {
+			assert.Equal(t, tt.body, w.Body.String())
+		}
+	}
+}
+
+func TestRouterV2_Constraints(t *testing.T) {
+	rt := NewRouterV2()
+	rt.Get("/v2/audio/:name", paramsHandler("name", "name"))
+	rt.Get("/v2/audio/{id:[0-9]+}", paramsHandler("id", "id"))
+	rt.Get("/v2/posts/{slug:[a-z0-9-]+}", paramsHandler("slug", "slug"))
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/v2/audio/42", 200, "id id=42"},
+		{"/v2/audio/intro", 200, "name name=intro"},
+		{"/v2/audio/4a", 200, "name name=4a"},
+		{"/v2/posts/hello-world-2", 200, "slug slug=hello-world-2"},
+		{"/v2/posts/Hello", 404, ""},
+	}
+	for _, tt := range tests {
+		w := serve(rt, http.MethodGet, tt.path)
+		assert.Equal(t, tt.code, w.Code)
+		if tt.code == 200 {
+			assert.Equal(t, tt.body, w.Body.String())
+		}
+	}
+}
+
+func TestRouterV2_BadConstraint(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected Handle to panic on a malformed constraint")
+		}
+	}()
+	NewRouterV2().Get("/v2/audio/{id:[0-9}", paramsHandler("id"))
+}
+
+func TestRouterV2_MethodNotAllowed(t *testing.T) {
+	rt := NewRouterV2()
+	rt.Get("/v2/audio/:id", paramsHandler("get", "id"))
+	rt.Post("/v2/audio/:id", paramsHandler("post", "id"))
+	rt.Put("/v2/audio", paramsHandler("put"))
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+		body   string
+		allow  string
+	}{
+		{http.MethodGet, "/v2/audio/1", 200, "get id=1", ""},
+		{http.MethodPost, "/v2/audio/1", 200, "post id=1", ""},
+		{http.MethodDelete, "/v2/audio/1", 405, "", "GET, HEAD, OPTIONS, POST"},
+		{http.MethodGet, "/v2/audio", 405, "", "OPTIONS, PUT"},
+		{http.MethodGet, "/v2/video", 404, "", ""},
+	}
+	for _, tt := range tests {
+		w := serve(rt, tt.method, tt.path)
+		assert.Equal(t, tt.code, w.Code)
+		assert.Equal(t, tt.allow, w.Header().Get(HeaderAllow))
+		if tt.code == 200 {
+			assert.Equal(t, tt.body, w.Body.String())
+		}
+	}
+}
+
+func TestRouterV2_HeadAndOptions(t *testing.T) {
+	var _ RouterInterface = (*RouterV2)(nil)
+	var _ RouterGroup = (*RouterV2Group)(nil)
+
+	rt := NewRouterV2()
+	rt.Get("/v2/audio/:id", paramsHandler("get", "id"))
+	rt.Any("/v2/any", paramsHandler("any"))
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+		body   string
+		allow  string
+	}{
+		{http.MethodHead, "/v2/audio/1", 200, "", ""},
+		{http.MethodOptions, "/v2/audio/1", 204, "", "GET, HEAD, OPTIONS"},
+		{http.MethodPatch, "/v2/any", 200, "any", ""},
+		{http.MethodOptions, "/v2/any", 200, "any", ""},
+	}
+	for _, tt := range tests {
+		w := serve(rt, tt.method, tt.path)
+		assert.Equal(t, tt.code, w.Code)
+		assert.Equal(t, tt.allow, w.Header().Get(HeaderAllow))
+		assert.Equal(t, tt.body, w.Body.String())
+	}
+}
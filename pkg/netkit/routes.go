@@ -0,0 +1,224 @@
+package netkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// RouteInfo describes a registered route, see Router.Routes.
+type RouteInfo struct {
+	Method     string    `json:"method"`
+	Pattern    string    `json:"pattern"`
+	Host       string    `json:"host,omitempty"`
+	Name       string    `json:"name,omitempty"`
+	Group      string    `json:"group,omitempty"`
+	Params     []string  `json:"params,omitempty"`
+	Middleware []string  `json:"middleware,omitempty"`
+	Matchers   []string  `json:"matchers,omitempty"`
+	Doc        *RouteDoc `json:"-"` // the documentation of the route, if it has any
+}
+
+// RouteLister is implemented by the routers that can list their routes.
+type RouteLister interface {
+	Routes() []RouteInfo
+}
+
+// Routes returns every route of the router, along with the routes of its
+// hosts, ordered by host, pattern, method and matchers. The middleware of a
+// route is listed in the order it runs in, starting with the middleware of
+// the router.
+func (rm *Router) Routes() []RouteInfo {
+	rt := rm.routes.Load()
+	var routes []RouteInfo
+	for _, table := range rt.tables() {
+		routes = appendRoutes(routes, table, rt.chain)
+	}
+	return sortRoutes(append(routes, rt.hosts.routes(rt.chain)...))
+}
+
+// Routes returns every route of the router, see Router.Routes.
+func (rt *RouterV2) Routes() []RouteInfo {
+	tbl := rt.routes.Load()
+	var routes []RouteInfo
+	tbl.tree.Walk(func(_ string, v any) bool {
+		if table, ok := v.(*methodTable); ok {
+			routes = appendRoutes(routes, table, tbl.chain)
+		}
+		return false
+	})
+	return sortRoutes(append(routes, tbl.hosts.routes(tbl.chain)...))
+}
+
+// appendRoutes appends the routes of the table, leaving out the implicit
+// entries, such as the roots of groups, and the shorter variants of patterns
+// with optional parameters, which are listed under their pattern.
+func appendRoutes(routes []RouteInfo, table *methodTable, chain *Chain) []RouteInfo {
+	for _, e := range table.sorted() {
+		if e.implicit || e.variant > 0 {
+			continue
+		}
+		routes = append(routes, RouteInfo{
+			Method:     e.method,
+			Pattern:    e.pattern,
+			Name:       e.name,
+			Group:      e.group,
+			Params:     append([]string(nil), e.params...),
+			Middleware: middlewareNames(append(append(chain.mw[:len(chain.mw):len(chain.mw)], e.groupChain.middleware()...), e.middleware...)),
+			Matchers:   matcherNames(e.matchers),
+			Doc:        e.doc.clone(),
+		})
+	}
+	return routes
+}
+
+// routes returns the routes of the sub-routers of the hosts, which run
+// behind the middleware of the chain.
+func (hs hostRoutes) routes(chain *Chain) []RouteInfo {
+	var routes []RouteInfo
+	for _, h := range hs {
+		rl, ok := h.handler.(RouteLister)
+		if !ok {
+			continue
+		}
+		for _, ri := range rl.Routes() {
+			if ri.Host == "" {
+				ri.Host = h.host.raw
+			}
+			ri.Middleware = append(middlewareNames(chain.mw), ri.Middleware...)
+			routes = append(routes, ri)
+		}
+	}
+	return routes
+}
+
+func sortRoutes(routes []RouteInfo) []RouteInfo {
+	sort.Slice(routes, func(i, j int) bool {
+		a, b := routes[i], routes[j]
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Pattern != b.Pattern {
+			return a.Pattern < b.Pattern
+		}
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		return strings.Join(a.Matchers, " && ") < strings.Join(b.Matchers, " && ")
+	})
+	return routes
+}
+
+// middlewareNames returns the names of the functions that built the
+// middleware, such as "netkit.CORSMiddleware", which stay the same from
+// one build to the next.
+func middlewareNames(mw []Middleware) []string {
+	var names []string
+	for _, m := range mw {
+		names = append(names, funcName(m))
+	}
+	return names
+}
+
+// matcherNames returns the descriptions of the matchers.
+func matcherNames(ms []Matcher) []string {
+	var names []string
+	for _, m := range ms {
+		names = append(names, m.desc)
+	}
+	return names
+}
+
+// funcName returns the name of the function f without its package path,
+// and without the suffixes the compiler gives to closures and method values.
+func funcName(f any) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+	name := fn.Name()
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSuffix(name, "-fm")
+	for {
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 || !isClosureName(name[i+1:]) {
+			return name
+		}
+		name = name[:i]
+	}
+}
+
+// isClosureName reports whether s is the name the compiler gives to a
+// closure, such as "func1", or to a closure nested in one, such as "2".
+func isClosureName(s string) bool {
+	s = strings.TrimPrefix(s, "func")
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteRoutesJSON writes the routes to w as an indented JSON array.
+func WriteRoutesJSON(w io.Writer, routes []RouteInfo) error {
+	if routes == nil {
+		routes = []RouteInfo{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(routes)
+}
+
+// WriteRoutesText writes the routes to w as a table with one route on each
+// line, which is meant to be read, and to be compared with diff. Empty
+// fields are written as "-".
+func WriteRoutesText(w io.Writer, routes []RouteInfo) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATTERN\tHOST\tNAME\tGROUP\tPARAMS\tMIDDLEWARE\tMATCHERS")
+	for _, ri := range routes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			ri.Method, ri.Pattern, orDash(ri.Host), orDash(ri.Name), orDash(ri.Group),
+			orDash(strings.Join(ri.Params, ",")), orDash(strings.Join(ri.Middleware, ",")),
+			orDash(strings.Join(ri.Matchers, " && ")),
+		)
+	}
+	return tw.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// RoutesHandler returns a handler that serves the routes of the router as
+// JSON when the path of the request ends in ".json", and as plain text
+// otherwise, so it can be registered at both "/routes.json" and "/routes".
+func RoutesHandler(rl RouteLister) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			routes := rl.Routes()
+			if strings.HasSuffix(r.URL.Path, ".json") {
+				w.Header().Set("Content-Type", mime.TypeByExtension(".json"))
+				WriteRoutesJSON(w, routes)
+				return
+			}
+			w.Header().Set("Content-Type", mime.TypeByExtension(".txt"))
+			WriteRoutesText(w, routes)
+		},
+	)
+}
@@ -0,0 +1,85 @@
+package netkit
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Jonny-Burkholder/streaming-example/pkg/assert"
+)
+
+func TestRoutes(t *testing.T) {
+	want := []RouteInfo{
+		{Method: "GET", Pattern: "/ping", Middleware: []string{"netkit.trace"}},
+		{Method: "*", Pattern: "/static", Middleware: []string{"netkit.trace"}},
+		{Method: "*", Pattern: "/static/*path", Middleware: []string{"netkit.trace"}},
+		{
+			Method:     "GET",
+			Pattern:    "/v1/audio/:id",
+			Name:       "audio.track",
+			Group:      "/v1",
+			Params:     []string{"id"},
+			Middleware: []string{"netkit.trace", "netkit.CORSMiddleware", "netkit.trace"},
+		},
+		{Method: "POST", Pattern: "/v1/audio/:id", Group: "/v1", Params: []string{"id"}, Middleware: []string{"netkit.trace", "netkit.CORSMiddleware"}},
+		{Method: "GET", Pattern: "/admin/{tenant}", Host: "media.example.com", Params: []string{"tenant"}, Middleware: []string{"netkit.trace"}},
+	}
+
+	rm := newTestRouter()
+	rm.Use(trace("router"))
+	v1 := rm.NewGroup("v1")
+	v1.Use(CORSMiddleware(nil))
+	v1.Get("/audio/:id", paramsHandler("track"), WithName("audio.track"), WithMiddleware(trace("route")))
+	v1.Post("/audio/:id", paramsHandler("upload"))
+	rm.Get("/ping", paramsHandler("ping"))
+	rm.Mount("/static", http.NotFoundHandler())
+	rm.Host("media.example.com").Get("/admin/{tenant}", paramsHandler("admin"))
+
+	rt := NewRouterV2()
+	rt.Use(trace("router"))
+	v2 := rt.NewGroup("v1")
+	v2.Use(CORSMiddleware(nil))
+	v2.Get("/audio/:id", paramsHandler("track"), WithName("audio.track"), WithMiddleware(trace("route")))
+	v2.Post("/audio/:id", paramsHandler("upload"))
+	rt.Get("/ping", paramsHandler("ping"))
+	rt.Mount("/static", http.NotFoundHandler())
+	rt.Host("media.example.com").Get("/admin/{tenant}", paramsHandler("admin"))
+
+	for _, rl := range []RouteLister{rm, rt} {
+		assert.Equal(t, want, rl.Routes())
+	}
+}
+
+func TestWriteRoutesText(t *testing.T) {
+	routes := []RouteInfo{
+		{Method: "GET", Pattern: "/v1/audio/:id", Name: "audio.track", Group: "/v1", Params: []string{"id"}, Middleware: []string{"netkit.trace"}},
+		{Method: "POST", Pattern: "/upload", Matchers: []string{"content type application/json"}},
+	}
+	var sb strings.Builder
+	err := WriteRoutesText(&sb, routes)
+	assert.Equal(t, nil, err)
+	want := "" +
+		"METHOD  PATTERN        HOST  NAME         GROUP  PARAMS  MIDDLEWARE    MATCHERS\n" +
+		"GET     /v1/audio/:id  -     audio.track  /v1    id      netkit.trace  -\n" +
+		"POST    /upload        -     -            -      -       -             content type application/json\n"
+	assert.Equal(t, want, sb.String())
+}
+
+func TestRoutesHandler(t *testing.T) {
+	rt := NewRouterV2()
+	rt.Get("/v2/audio/:id", paramsHandler("track"), WithName("audio.track"))
+	rt.Get("/routes.json", RoutesHandler(rt).ServeHTTP)
+	rt.Get("/routes", RoutesHandler(rt).ServeHTTP)
+
+	w := serve(rt, "GET", "/routes.json")
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var routes []RouteInfo
+	err := json.Unmarshal(w.Body.Bytes(), &routes)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, rt.Routes(), routes)
+
+	w = serve(rt, "GET", "/routes")
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, true, strings.Contains(w.Body.String(), "GET     /v2/audio/:id  -     audio.track"))
+}
@@ -0,0 +1,178 @@
+package netkit
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Jonny-Burkholder/streaming-example/pkg/trees/radix"
+)
+
+// routeNames maps the names of routes onto the patterns they were
+// registered with, so their URLs can be built.
+type routeNames map[string]*Pattern
+
+// add stores the pattern under the name. It panics if the name is already
+// in use by a different pattern. The same name may be shared by the routes
+// of several methods that use the same pattern.
+func (rn routeNames) add(name string, pat *Pattern) {
+	if old, exist := rn[name]; exist && old.raw != pat.raw {
+		panic(fmt.Sprintf("netkit: route name %q is used by both %q and %q", name, old.raw, pat.raw))
+	}
+	rn[name] = pat
+}
+
+// release gives up the name once the table no longer holds an entry that
+// uses it, whether it is a plain entry or one with matchers.
+func (rn routeNames) release(name string, t *methodTable) {
+	if name == "" {
+		return
+	}
+	for _, e := range t.entries {
+		if e.name == name {
+			return
+		}
+	}
+	for _, vs := range t.variants {
+		for _, e := range vs {
+			if e.name == name {
+				return
+			}
+		}
+	}
+	delete(rn, name)
+}
+
+// url builds the URL of the named route, see Router.URL.
+func (rn routeNames) url(name string, pairs []string) (string, error) {
+	pat, found := rn[name]
+	if !found {
+		return "", fmt.Errorf("netkit: no route named %q", name)
+	}
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("netkit: route %q: odd number of parameter pairs", name)
+	}
+	ps := make(Params, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		ps = append(ps, PathParam{Key: pairs[i], Value: pairs[i+1]})
+	}
+	path, err := pat.build(ps)
+	if err != nil {
+		return "", fmt.Errorf("netkit: route %q: %w", name, err)
+	}
+	return path, nil
+}
+
+// build fills in the parameters of the pattern with the provided values,
+// and returns the resulting path. The values are escaped, and each one
+// has to satisfy the constraint of its parameter. Every parameter needs
+// a value, and every value needs a parameter, but a catch-all may be
+// empty. Trailing optional parameters without a value are left out,
+// along with their segments.
+func (p *Pattern) build(ps Params) (string, error) {
+	for _, param := range ps {
+		if !p.hasParam(param.Key) {
+			return "", fmt.Errorf("unknown parameter %q", param.Key)
+		}
+	}
+	n := 0
+	for n < len(p.shorter) && ps.Get(p.names[len(p.names)-1-n]) == "" {
+		n++
+	}
+	if n > 0 {
+		short := p.shorter[n-1]
+		kept := make(Params, 0, len(ps))
+		for _, param := range ps {
+			if short.hasParam(param.Key) {
+				kept = append(kept, param)
+			}
+		}
+		return short.build(kept)
+	}
+	var sb strings.Builder
+	var i, k int
+	for _, seg := range p.segs {
+		if seg.Kind == radix.Static {
+			sb.WriteString(seg.Text)
+			continue
+		}
+		if seg.Kind == radix.Mixed {
+			m := p.mixed[k]
+			k++
+			v, err := m.build(ps)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(v)
+			i += m.params()
+			continue
+		}
+		name := p.names[i]
+		i++
+		v, ok := ps.Lookup(name)
+		if !ok || (v == "" && seg.Kind != radix.CatchAll) {
+			return "", fmt.Errorf("missing value for parameter %q", name)
+		}
+		if seg.Kind == radix.CatchAll {
+			parts := strings.Split(v, "/")
+			for j := range parts {
+				parts[j] = url.PathEscape(parts[j])
+			}
+			sb.WriteString(strings.Join(parts, "/"))
+			continue
+		}
+		if strings.IndexByte(v, '/') != -1 {
+			return "", fmt.Errorf("value %q for parameter %q must not contain '/'", v, name)
+		}
+		if seg.Match != nil && !seg.Match(v) {
+			return "", fmt.Errorf("value %q for parameter %q does not satisfy %q", v, name, seg.Text)
+		}
+		sb.WriteString(url.PathEscape(v))
+	}
+	return sb.String(), nil
+}
+
+// build fills in the parameters of the mixed segment, see Pattern.build.
+// Since the values could contain the literal text that separates them, the
+// segment is matched again, and the values have to come back unchanged.
+func (m mixedSegment) build(ps Params) (string, error) {
+	var raw, sb strings.Builder
+	var want []string
+	for _, pc := range m {
+		if !pc.param {
+			raw.WriteString(pc.text)
+			sb.WriteString(url.PathEscape(pc.text))
+			continue
+		}
+		v, _ := ps.Lookup(pc.text)
+		if v == "" {
+			return "", fmt.Errorf("missing value for parameter %q", pc.text)
+		}
+		if strings.IndexByte(v, '/') != -1 {
+			return "", fmt.Errorf("value %q for parameter %q must not contain '/'", v, pc.text)
+		}
+		if pc.match != nil && !pc.match(v) {
+			return "", fmt.Errorf("value %q for parameter %q does not satisfy %q", v, pc.text, pc.expr)
+		}
+		raw.WriteString(v)
+		sb.WriteString(url.PathEscape(v))
+		want = append(want, v)
+	}
+	got, _ := m.capture(raw.String(), nil)
+	for j := range want {
+		if got[j] != want[j] {
+			return "", fmt.Errorf("values %q would be matched as %q by %q", want, got, m.shape())
+		}
+	}
+	return sb.String(), nil
+}
+
+// hasParam reports whether the pattern has a parameter with the name.
+func (p *Pattern) hasParam(name string) bool {
+	for _, n := range p.names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package netkit
+
+import (
+	"testing"
+
+	"github.com/Jonny-Burkholder/streaming-example/pkg/assert"
+)
+
+func TestRouter_URL(t *testing.T) {
+	rm := newTestRouter()
+	rm.Get("/audio", paramsHandler("list"), WithName("audio.list"))
+	rm.Get("/audio/{id:[0-9]+}", paramsHandler("track"), WithName("audio.track"))
+	rm.Post("/audio/{id:[0-9]+}", paramsHandler("track"), WithName("audio.track"))
+	v1 := rm.NewGroup("v1")
+	v1.Get("/users/:user/files/*path", paramsHandler("files"), WithName("v1.files"))
+
+	tests := []struct {
+		name  string
+		pairs []string
+		url   string
+		valid bool
+	}{
+		{"audio.list", nil, "/audio", true},
+		{"audio.track", []string{"id", "42"}, "/audio/42", true},
+		{"v1.files", []string{"user", "jo hn", "path", "a b/c.mp3"}, "/v1/users/jo%20hn/files/a%20b/c.mp3", true},
+		{"v1.files", []string{"user", "john", "path", ""}, "/v1/users/john/files/", true},
+		{"audio.track", []string{"id", "abc"}, "", false},
+		{"audio.track", nil, "", false},
+		{"audio.track", []string{"id"}, "", false},
+		{"audio.track", []string{"id", "1", "x", "2"}, "", false},
+		{"v1.files", []string{"user", "a/b", "path", "c"}, "", false},
+		{"missing", nil, "", false},
+	}
+	for _, tt := range tests {
+		u, err := rm.URL(tt.name, tt.pairs...)
+		if !tt.valid {
+			if err == nil {
+				t.Errorf("URL(%q, %q): expected an error, got %q", tt.name, tt.pairs, u)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("URL(%q, %q): unexpected error: %s", tt.name, tt.pairs, err)
+			continue
+		}
+		assert.Equal(t, tt.url, u)
+	}
+}
+
+func TestRouterV2_URL(t *testing.T) {
+	rt := NewRouterV2()
+	v2 := rt.NewGroup("v2")
+	v2.Get("/audio/:id", paramsHandler("track"), WithName("audio.track"))
+	u, err := v2.URL("audio.track", "id", "42")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	assert.Equal(t, "/v2/audio/42", u)
+}
+
+func TestRouteNames_Conflict(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic when a name is reused by another pattern")
+		}
+	}()
+	rt := NewRouterV2()
+	rt.Get("/a", paramsHandler("a"), WithName("dup"))
+	rt.Get("/b", paramsHandler("b"), WithName("dup"))
+}
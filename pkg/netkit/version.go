@@ -0,0 +1,216 @@
+package netkit
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type VersionConfig struct {
+	// Header is the request header that carries the version the client
+	// asks for, such as "2" or "v2".
+	//
+	// Optional. Default value "Accept-Version"
+	Header string
+
+	// Vendor is the name used in the vendor media types of the Accept
+	// header, such as "stream" in "application/vnd.stream.v2+json". The
+	// Accept header is only read when Vendor is set, and Header takes
+	// precedence over it.
+	//
+	// Optional. Default value "".
+	Vendor string
+
+	// Default is the version that serves the requests that do not ask for
+	// a version.
+	//
+	// Required.
+	Default string
+}
+
+// Versions dispatches the requests for a path to the handler of the API
+// version that the client asks for in its headers, so clients that can not
+// change their URLs can still pick a version. It works alongside groups such
+// as "/v1" and "/v2", see Middleware.
+type Versions struct {
+	header     string
+	vendor     string
+	def        string
+	lock       sync.RWMutex
+	deprecated map[string]deprecation
+}
+
+// deprecation holds the values of the headers that the responses of a
+// deprecated version carry, see Versions.Deprecate.
+type deprecation struct {
+	at     string // the Deprecation header, as "@" and a Unix time
+	sunset string // the Sunset header, or "" if there is none
+}
+
+// Versioned holds the handlers of a path by version, see Versions.Handler.
+type Versioned map[string]http.Handler
+
+// NewVersions returns a version set configured by c. It panics if c does not
+// have a default version.
+func NewVersions(c *VersionConfig) *Versions {
+	if c == nil || c.Default == "" {
+		panic("netkit: versions need a default version")
+	}
+	v := &Versions{
+		header:     c.Header,
+		vendor:     c.Vendor,
+		def:        normalizeVersion(c.Default),
+		deprecated: make(map[string]deprecation),
+	}
+	if v.header == "" {
+		v.header = HeaderAcceptVersion
+	}
+	return v
+}
+
+// Deprecate marks the version as deprecated as of the time at, so that its
+// responses carry a Deprecation header as defined by RFC 9745, such as
+// "@1767225600", along with a Sunset header giving the time after which the
+// version may stop working, unless sunset is the zero time. The version is
+// deprecated as of the call if at is the zero time.
+func (v *Versions) Deprecate(version string, at, sunset time.Time) {
+	if at.IsZero() {
+		at = time.Now()
+	}
+	d := deprecation{at: fmt.Sprintf("@%d", at.Unix())}
+	if !sunset.IsZero() {
+		d.sunset = sunset.UTC().Format(http.TimeFormat)
+	}
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.deprecated[normalizeVersion(version)] = d
+}
+
+// Handler returns a handler that serves each request with the handler of
+// the version it asks for. Requests that do not ask for a version are served
+// by the default version, and requests that ask for a version that is not
+// in hs are answered with 406 Not Acceptable. It panics if hs has no handler
+// for the default version.
+func (v *Versions) Handler(hs Versioned) http.Handler {
+	handlers := make(map[string]http.Handler, len(hs))
+	for version, h := range hs {
+		if h == nil {
+			panic("http: nil handler")
+		}
+		handlers[normalizeVersion(version)] = h
+	}
+	if handlers[v.def] == nil {
+		panic(fmt.Sprintf("netkit: no handler for the default version %q", v.def))
+	}
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add(HeaderVary, v.vary())
+			version := v.requested(r)
+			h, ok := handlers[version]
+			if !ok {
+				code := http.StatusNotAcceptable
+				http.Error(w, fmt.Sprintf("unsupported API version %q, supported versions are %s", version, listVersions(handlers)), code)
+				return
+			}
+			v.serve(version, h, w, r)
+		},
+	)
+}
+
+// Middleware returns middleware that serves the requests as the version,
+// which is meant for the groups that carry the version in their prefix, so
+// that they report their version, and their deprecation, just like Handler.
+func (v *Versions) Middleware(version string) Middleware {
+	version = normalizeVersion(version)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				v.serve(version, next, w, r)
+			},
+		)
+	}
+}
+
+// serve serves the request with h as the version, adding the deprecation
+// headers if the version is deprecated.
+func (v *Versions) serve(version string, h http.Handler, w http.ResponseWriter, r *http.Request) {
+	v.lock.RLock()
+	d, deprecated := v.deprecated[version]
+	v.lock.RUnlock()
+	if deprecated {
+		w.Header().Set(HeaderDeprecation, d.at)
+		if d.sunset != "" {
+			w.Header().Set(HeaderSunset, d.sunset)
+		}
+	}
+	h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), versionContextKey, version)))
+}
+
+// requested returns the version that the request asks for, or the default
+// version if it does not ask for one.
+func (v *Versions) requested(r *http.Request) string {
+	if s := r.Header.Get(v.header); s != "" {
+		return normalizeVersion(s)
+	}
+	if v.vendor == "" {
+		return v.def
+	}
+	prefix := "application/vnd." + v.vendor + ".v"
+	for _, accept := range r.Header.Values(HeaderAccept) {
+		for _, mt := range strings.Split(accept, ",") {
+			mt, _, err := mime.ParseMediaType(mt)
+			if err != nil || !strings.HasPrefix(mt, prefix) {
+				continue
+			}
+			version, _, _ := strings.Cut(mt[len(prefix):], "+")
+			if version != "" {
+				return normalizeVersion(version)
+			}
+		}
+	}
+	return v.def
+}
+
+// vary returns the value of the Vary header, which names the headers that
+// select the version, so that caches keep the versions apart.
+func (v *Versions) vary() string {
+	if v.vendor == "" {
+		return v.header
+	}
+	return v.header + ", " + HeaderAccept
+}
+
+// normalizeVersion strips the spaces and the "v" prefix from a version, so
+// that "v2" and "2" are the same version.
+func normalizeVersion(version string) string {
+	version = strings.TrimSpace(version)
+	if len(version) > 1 && (version[0] == 'v' || version[0] == 'V') {
+		version = version[1:]
+	}
+	return version
+}
+
+func listVersions(handlers map[string]http.Handler) string {
+	versions := make([]string, 0, len(handlers))
+	for version := range handlers {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	return strings.Join(versions, ", ")
+}
+
+// versionContextKey is the context key that holds the API version the
+// request is served as.
+var versionContextKey = &contextKey{"version"}
+
+// APIVersion returns the API version that the request is served as by
+// Versions, or an empty string if it is not served by Versions.
+func APIVersion(r *http.Request) string {
+	version, _ := r.Context().Value(versionContextKey).(string)
+	return version
+}
@@ -0,0 +1,128 @@
+package netkit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Jonny-Burkholder/streaming-example/pkg/assert"
+)
+
+// versionHandler writes the name along with the API version of the request.
+func versionHandler(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s version=%s", name, APIVersion(r))
+	}
+}
+
+func TestVersions(t *testing.T) {
+	at := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC)
+	versions := NewVersions(&VersionConfig{Vendor: "stream", Default: "2"})
+	versions.Deprecate("v1", at, sunset)
+
+	rt := NewRouterV2()
+	rt.Get("/audio/:id", versions.Handler(Versioned{
+		"1": versionHandler("v1"),
+		"2": versionHandler("v2"),
+	}).ServeHTTP)
+
+	tests := []struct {
+		header string
+		value  string
+		code   int
+		body   string
+		sunset string
+	}{
+		{"", "", 200, "v2 version=2", ""},
+		{"Accept-Version", "1", 200, "v1 version=1", "Fri, 01 Jan 2027 00:00:00 GMT"},
+		{"Accept-Version", "v2", 200, "v2 version=2", ""},
+		{"Accept", "application/vnd.stream.v1+json", 200, "v1 version=1", "Fri, 01 Jan 2027 00:00:00 GMT"},
+		{"Accept", "text/html, application/vnd.stream.v2+json;q=0.9", 200, "v2 version=2", ""},
+		{"Accept", "application/vnd.other.v1+json", 200, "v2 version=2", ""},
+		{"Accept", "application/json", 200, "v2 version=2", ""},
+		{"Accept-Version", "3", 406, "unsupported API version \"3\", supported versions are 1, 2\n", ""},
+		{"Accept", "application/vnd.stream.v3+json", 406, "unsupported API version \"3\", supported versions are 1, 2\n", ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/audio/42", nil)
+		if tt.header != "" {
+			r.Header.Set(tt.header, tt.value)
+		}
+		rt.ServeHTTP(w, r)
+		assert.Equal(t, tt.code, w.Code)
+		assert.Equal(t, tt.body, w.Body.String())
+		assert.Equal(t, "Accept-Version, Accept", w.Header().Get(HeaderVary))
+		assert.Equal(t, tt.sunset, w.Header().Get(HeaderSunset))
+		deprecated := ""
+		if tt.sunset != "" {
+			deprecated = "@1767225600"
+		}
+		assert.Equal(t, deprecated, w.Header().Get(HeaderDeprecation))
+	}
+
+	// the version header wins over the Accept header
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/audio/42", nil)
+	r.Header.Set("Accept-Version", "2")
+	r.Header.Set("Accept", "application/vnd.stream.v1+json")
+	rt.ServeHTTP(w, r)
+	assert.Equal(t, "v2 version=2", w.Body.String())
+}
+
+func TestVersions_Middleware(t *testing.T) {
+	versions := NewVersions(&VersionConfig{Header: "X-API-Version", Default: "2"})
+	before := time.Now().Unix()
+	versions.Deprecate("1", time.Time{}, time.Time{})
+
+	rm := newTestRouter()
+	v1 := rm.NewGroup("v1")
+	v1.Use(versions.Middleware("v1"))
+	v1.Get("/audio", versionHandler("v1"))
+	v2 := rm.NewGroup("v2")
+	v2.Use(versions.Middleware("2"))
+	v2.Get("/audio", versionHandler("v2"))
+	rm.Get("/audio", versions.Handler(Versioned{"1": versionHandler("v1"), "2": versionHandler("v2")}).ServeHTTP)
+
+	w := serve(rm, "GET", "/v1/audio")
+	assert.Equal(t, "v1 version=1", w.Body.String())
+	// without a time, the version is deprecated as of the call
+	var at int64
+	fmt.Sscanf(w.Header().Get(HeaderDeprecation), "@%d", &at)
+	assert.Equal(t, true, before <= at && at <= time.Now().Unix())
+	assert.Equal(t, "", w.Header().Get(HeaderSunset))
+
+	w = serve(rm, "GET", "/v2/audio")
+	assert.Equal(t, "v2 version=2", w.Body.String())
+	assert.Equal(t, "", w.Header().Get(HeaderDeprecation))
+
+	// without a vendor, only the configured header selects the version
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/audio", nil)
+	r.Header.Set("X-API-Version", "1")
+	r.Header.Set("Accept", "application/vnd.stream.v2+json")
+	rm.ServeHTTP(w, r)
+	assert.Equal(t, "v1 version=1", w.Body.String())
+	assert.Equal(t, "X-API-Version", w.Header().Get(HeaderVary))
+}
+
+func TestVersions_Panics(t *testing.T) {
+	tests := []func(){
+		func() { NewVersions(nil) },
+		func() { NewVersions(&VersionConfig{}) },
+		func() {
+			NewVersions(&VersionConfig{Default: "2"}).Handler(Versioned{"1": versionHandler("v1")})
+		},
+	}
+	for _, fn := range tests {
+		func() {
+			defer func() {
+				assert.Equal(t, true, recover() != nil)
+			}()
+			fn()
+		}()
+	}
+}
@@ -135,10 +135,10 @@ func Benchmark_HTTP_NetKitRouter(b *testing.B) {
 	)
 	mux.HandleFunc(
 		http.MethodGet,
-		"/api/users/", func(w http.ResponseWriter, r *http.Request) {
-			id := getPathID(r.URL.Path)
-			if id < 0 {
-				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		"/api/users/{id:int}", func(w http.ResponseWriter, r *http.Request) {
+			id, err := netkit.ParamInt(r, "id")
+			if err != nil {
+				netkit.WriteErrorJSON(w, r, http.StatusBadRequest, err)
 				return
 			}
 			fmt.Fprintf(w, "USERS %d ROOT", id)
@@ -254,3 +254,21 @@ func Benchmark_HTTP_CustomRegexRouter(b *testing.B) {
 		}
 	}
 }
+
+func TestRegexURLMatcher_Precedence(t *testing.T) {
+	patterns := []string{`/api/users/{id}`, `/api/users/new`}
+	for _, order := range [][]int{{0, 1}, {1, 0}} {
+		mux := NewRegexURLMatcher()
+		for _, i := range order {
+			p := patterns[i]
+			mux.HandleFunc(http.MethodGet, p, func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, p)
+			})
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/users/new", nil))
+		if w.Body.String() != `/api/users/new` {
+			t.Errorf("order %v: got %q, want %q", order, w.Body.String(), `/api/users/new`)
+		}
+	}
+}
@@ -4,70 +4,80 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
-	"regexp"
-	"strings"
-)
 
-func sanitizePattern(path string) string {
-	i := strings.IndexByte(path, '{')
-	j := strings.IndexByte(path, '}')
-	if i > 0 && j > 0 {
-		path = path[:i] + `(?P<` + path[i+1:j] + `>[a-zA-Z0-9]+)`
-	}
-	if path[0] != '^' {
-		path = "^" + path
-	}
-	if path[len(path)-1] != '$' {
-		path = path + "$"
-	}
-	return path
-}
+	"github.com/Jonny-Burkholder/streaming-example/pkg/netkit"
+)
 
 type reRoute struct {
 	method  string
-	pattern string
-	re      *regexp.Regexp
+	pattern *netkit.Pattern
 	h       http.HandlerFunc
 }
 
+// precedes reports whether the route takes precedence over other, so that
+// the outcome never depends on the order the routes were registered in.
+func (r *reRoute) precedes(other *reRoute) bool {
+	if c := r.pattern.Specificity().Compare(other.pattern.Specificity()); c != 0 {
+		return c > 0
+	}
+	return r.pattern.String() < other.pattern.String()
+}
+
 func (r *reRoute) String() string {
-	return fmt.Sprintf("method=%q, pattern=%q, regex=%q, handler=%v\n", r.method, r.pattern, r.re, r.h)
+	return fmt.Sprintf("method=%q, pattern=%q, handler=%v\n", r.method, r.pattern, r.h)
 }
 
+// RegexURLMatcher keeps its routes ordered by precedence, so the first
+// route that matches a request is the one that serves it. The patterns
+// are compiled with netkit.CompilePattern, so parameters may carry regular
+// expressions, as in "/api/users/{id:[0-9]+}", and their values are added
+// to the form of the request.
 type RegexURLMatcher struct {
-	routes map[string]*reRoute
+	routes []*reRoute
 }
 
 func NewRegexURLMatcher() *RegexURLMatcher {
 	return &RegexURLMatcher{
-		routes: make(map[string]*reRoute),
+		routes: make([]*reRoute, 0),
 	}
 }
 
 func (re *RegexURLMatcher) HandleFunc(method string, pattern string, handler http.HandlerFunc) {
-	pattern = sanitizePattern(pattern)
-	compiled, err := regexp.Compile(pattern)
+	compiled, err := netkit.CompilePattern(pattern)
 	if err != nil {
 		panic(err)
 	}
-	re.routes[pattern] = &reRoute{
+	route := &reRoute{
 		method:  method,
-		pattern: pattern,
-		re:      compiled,
+		pattern: compiled,
 		h:       handler,
 	}
+	for i, r := range re.routes {
+		if r.method == method && r.pattern.String() == pattern {
+			re.routes[i] = route
+			return
+		}
+	}
+	i := 0
+	for i < len(re.routes) && !route.precedes(re.routes[i]) {
+		i++
+	}
+	re.routes = append(re.routes, nil)
+	copy(re.routes[i+1:], re.routes[i:])
+	re.routes[i] = route
 }
 
 func (re *RegexURLMatcher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	for _, route := range re.routes {
-		if route.method == r.Method && route.re.MatchString(r.URL.Path) {
-			if sub := route.re.SubexpNames(); len(sub) > 1 {
-				for at, s := range sub[1:] {
-					if r.Form == nil {
-						r.Form = url.Values{}
-					}
-					r.Form.Set(s, route.re.FindStringSubmatch(r.URL.Path)[at+1])
+		if route.method != r.Method {
+			continue
+		}
+		if ps, ok := route.pattern.Match(r.URL.Path); ok {
+			for _, p := range ps {
+				if r.Form == nil {
+					r.Form = url.Values{}
 				}
+				r.Form.Set(p.Key, p.Value)
 			}
 			route.h(w, r)
 			return
@@ -3,6 +3,7 @@ package radix
 import (
 	"sort"
 	"strings"
+	"sync/atomic"
 )
 
 type leafNode struct {
@@ -28,6 +29,24 @@ type node struct {
 	// since in most cases we expect the set to
 	// be rather sparse.
 	edges edges
+
+	// wild contains the wildcard children of this node. They
+	// are only created by InsertRoute, and they are tried in
+	// order after the static edges have failed to match.
+	wild []*node
+
+	// kind reports how this node matches, and ident is used to
+	// tell apart wildcard nodes of the same kind. A wildcard node
+	// may have a match function that constrains its values, and a
+	// mixed node has a capture function that captures them.
+	kind    Kind
+	ident   string
+	match   func(string) bool
+	capture func(string, []string) ([]string, bool)
+
+	// gen is the generation of the tree that created the node, see
+	// Tree.Clone. Only a tree of the same generation may change it.
+	gen uint64
 }
 
 func (n *node) isLeaf() bool {
@@ -88,12 +107,21 @@ func (n *node) delEdge(label byte) {
 	}
 }
 
+// mergeChild merges the only child of n into n. The child may be shared
+// with a clone of the tree, so its edges are copied.
 func (n *node) mergeChild() {
 	e := n.edges[0]
 	child := e.node
 	n.prefix = n.prefix + child.prefix
 	n.leaf = child.leaf
-	n.edges = child.edges
+	n.edges = append(edges(nil), child.edges...)
+	n.wild = append([]*node(nil), child.wild...)
+}
+
+// canMerge reports whether the node may be merged with its only child.
+// Wildcard nodes never carry a prefix, so they are never merged.
+func (n *node) canMerge() bool {
+	return n.kind == Static && len(n.edges) == 1 && len(n.wild) == 0
 }
 
 type edges []edge
@@ -121,17 +149,97 @@ func (e edges) sortEdges() {
 // not be space or time optimized if the data set does not share
 // many common prefixes--in which case a hashmap or RedBlackTree
 // would be preferred.
+//
+// A Tree may be read by any number of goroutines while another one clones
+// it, as Clone only changes gen, which only the methods that change the
+// tree ever read. Lookup, Find, Walk and the other methods that read the
+// tree must never read gen.
 type Tree struct {
 	root *node
 	size int
+
+	// gen is the generation of the tree, see Clone. It is only read and
+	// written by Clone and by the methods that change the tree.
+	gen uint64
 }
 
+// generations hands out the generations of trees, see Tree.Clone.
+var generations atomic.Uint64
+
 // NewTree returns a new pointer to an empty Tree (radix tree)
 func NewTree() *Tree {
+	gen := generations.Add(1)
 	return &Tree{
-		root: new(node),
+		root: &node{gen: gen},
 		size: 0,
+		gen:  gen,
+	}
+}
+
+// Clone returns a copy of the tree, which can be changed without
+// affecting t. The values themselves are shared by both trees. Clone
+// takes constant time: both trees share their nodes, and each of them
+// copies a node the first time it changes it, along with the nodes on
+// the path to it.
+//
+// Both trees are given a new generation, as t would otherwise go on to
+// change the nodes it now shares with the copy. Clone changes nothing else
+// in t, so t may be read while it is cloned, see Tree.
+func (t *Tree) Clone() *Tree {
+	t.gen = generations.Add(1)
+	return &Tree{
+		root: t.root,
+		size: t.size,
+		gen:  generations.Add(1),
+	}
+}
+
+// writable returns n if it belongs to the generation of the tree, and
+// otherwise a copy of it that does. The leaf of the node is shared, so it
+// is replaced rather than changed.
+func (t *Tree) writable(n *node) *node {
+	if n.gen == t.gen {
+		return n
 	}
+	c := *n
+	c.gen = t.gen
+	c.edges = append(edges(nil), n.edges...)
+	c.wild = append([]*node(nil), n.wild...)
+	return &c
+}
+
+// writableRoot makes the root of the tree writable, and returns it.
+func (t *Tree) writableRoot() *node {
+	t.root = t.writable(t.root)
+	return t.root
+}
+
+// writableEdge returns the writable child of n, which must be writable
+// itself, for the label, or nil if there is none.
+func (t *Tree) writableEdge(n *node, label byte) *node {
+	c := n.getEdge(label)
+	if c == nil {
+		return nil
+	}
+	if w := t.writable(c); w != c {
+		n.updateEdge(label, w)
+		return w
+	}
+	return c
+}
+
+// writableWild returns the writable copy of the wildcard child c of n,
+// which must be writable itself.
+func (t *Tree) writableWild(n, c *node) *node {
+	w := t.writable(c)
+	if w != c {
+		for i := range n.wild {
+			if n.wild[i] == c {
+				n.wild[i] = w
+			}
+		}
+	}
+	return w
 }
 
 // longestPrefix finds the (longest) length of a shared
@@ -154,7 +262,7 @@ func longestPrefix(k1, k2 string) int {
 // Returns a boolean indicating true if an old value was updated.
 func (t *Tree) Insert(k string, v any) (any, bool) {
 	var parent *node
-	n := t.root
+	n := t.writableRoot()
 	search := k
 	for {
 		// Handle key exhaustion
@@ -162,7 +270,7 @@ func (t *Tree) Insert(k string, v any) (any, bool) {
 			if n.isLeaf() {
 				// update old value
 				old := n.leaf.val
-				n.leaf.val = v
+				n.leaf = &leafNode{key: n.leaf.key, val: v}
 				return old, true
 			}
 			// otherwise, create a
@@ -177,7 +285,7 @@ func (t *Tree) Insert(k string, v any) (any, bool) {
 
 		// Look for the edge
 		parent = n
-		n = n.getEdge(search[0])
+		n = t.writableEdge(parent, search[0])
 
 		// No edge found, create a new one
 		if n == nil {
@@ -189,6 +297,7 @@ func (t *Tree) Insert(k string, v any) (any, bool) {
 						val: v,
 					},
 					prefix: search,
+					gen:    t.gen,
 				},
 			}
 			parent.addEdge(e)
@@ -207,6 +316,7 @@ func (t *Tree) Insert(k string, v any) (any, bool) {
 		t.size++
 		child := &node{
 			prefix: search[:common],
+			gen:    t.gen,
 		}
 		parent.updateEdge(search[0], child)
 
@@ -239,6 +349,7 @@ func (t *Tree) Insert(k string, v any) (any, bool) {
 				node: &node{
 					leaf:   leaf,
 					prefix: search,
+					gen:    t.gen,
 				},
 			},
 		)
@@ -249,9 +360,12 @@ func (t *Tree) Insert(k string, v any) (any, bool) {
 // Delete is used to delete a key. It will return the previous
 // value and a boolean indicating true if it was deleted.
 func (t *Tree) Delete(k string) (any, bool) {
+	if _, found := t.Find(k); !found {
+		return nil, false
+	}
 	var parent *node
 	var label byte
-	n := t.root
+	n := t.writableRoot()
 	search := k
 	for {
 		// Check for key exhaustion
@@ -265,7 +379,7 @@ func (t *Tree) Delete(k string) (any, bool) {
 		// Look for an edge
 		parent = n
 		label = search[0]
-		n = n.getEdge(label)
+		n = t.writableEdge(parent, label)
 		if n == nil {
 			break
 		}
@@ -286,17 +400,17 @@ delete:
 	t.size--
 
 	// Check if we need to delete this node (from the parent)
-	if parent != nil && len(n.edges) == 0 {
+	if parent != nil && len(n.edges) == 0 && len(n.wild) == 0 {
 		parent.delEdge(label)
 	}
 
 	// Check if we need to merge this node
-	if n != t.root && len(n.edges) == 1 {
+	if n != t.root && n.canMerge() {
 		n.mergeChild()
 	}
 
 	// Check if we need to merge the sibling
-	if parent != nil && parent != t.root && len(parent.edges) == 1 && !parent.isLeaf() {
+	if parent != nil && parent != t.root && parent.canMerge() && !parent.isLeaf() {
 		parent.mergeChild()
 	}
 	return leaf.val, true
@@ -306,7 +420,7 @@ delete:
 // returns the number of nodes were deleted. This method can be used to
 // remove a large subtree efficiently.
 func (t *Tree) DeletePrefix(k string) int {
-	return t.deletePrefixRecursive(nil, t.root, k)
+	return t.deletePrefixRecursive(nil, t.writableRoot(), k)
 }
 
 // deletePrefixRecursive does a recursive subtree removal
@@ -324,9 +438,10 @@ func (t *Tree) deletePrefixRecursive(parent, n *node, prefix string) int {
 			n.leaf = nil
 		}
 		n.edges = nil
+		n.wild = nil
 
 		// Check if we need to marge the sibling
-		if parent != nil && parent != t.root && len(parent.edges) == 1 && !parent.isLeaf() {
+		if parent != nil && parent != t.root && parent.canMerge() && !parent.isLeaf() {
 			parent.mergeChild()
 		}
 		t.size -= subTreeSize
@@ -339,6 +454,7 @@ func (t *Tree) deletePrefixRecursive(parent, n *node, prefix string) int {
 	if child == nil || (!strings.HasPrefix(child.prefix, prefix) && !strings.HasPrefix(prefix, child.prefix)) {
 		return 0
 	}
+	child = t.writableEdge(n, label)
 
 	// Consume the search prefix
 	if len(child.prefix) > len(prefix) {
@@ -364,6 +480,11 @@ func recursiveWalk(n *node, fn WalkFn) bool {
 			return true
 		}
 	}
+	for _, w := range n.wild {
+		if recursiveWalk(w, fn) {
+			return true
+		}
+	}
 	return false
 }
 
@@ -0,0 +1,366 @@
+package radix
+
+import (
+	"strings"
+)
+
+// Kind describes how a Segment of a route key is matched.
+type Kind uint8
+
+const (
+	// Static segments match their text byte for byte.
+	Static Kind = iota
+
+	// Param segments match a single, non-empty path segment, which
+	// is everything up to (but not including) the next '/'.
+	Param
+
+	// CatchAll segments match the remainder of the path, including
+	// any slashes, and may match an empty remainder. A catch-all must
+	// be the last segment of a route.
+	CatchAll
+
+	// Mixed segments match a single, non-empty path segment just like
+	// Param segments, but the values they capture, if any, are left
+	// to their Capture function, so that a segment such as "{name}.{ext}"
+	// can capture several values.
+	Mixed
+)
+
+// Segment is a single piece of a route key. A route key is a list of
+// segments that are matched in order. Static segments are stored in
+// the tree just like the keys given to Insert, and wildcard segments
+// become wildcard nodes that capture a value when a path is matched.
+// For Static segments, Text holds the literal text. For wildcards it
+// is only used to tell apart wildcards of the same kind, so wildcards
+// with equal Text share a node.
+//
+// A Param segment may also carry a Match function, which constrains
+// the values it will capture. When Match returns false, the tree moves
+// on to the next candidate, just as if the segment did not match.
+//
+// A Mixed segment must carry a Capture function, which is given the path
+// segment and appends the values it captures to vals. When it returns
+// false, the tree moves on to the next candidate.
+type Segment struct {
+	Kind    Kind
+	Text    string
+	Match   func(string) bool
+	Capture func(seg string, vals []string) ([]string, bool)
+}
+
+// InsertRoute is like Insert, but the key is described by a list of
+// segments, which may contain wildcards. The key string is only stored
+// alongside the value so that it can be reported by Walk and Lookup.
+// Returns the old value and a boolean indicating true if an existing
+// route was updated.
+func (t *Tree) InsertRoute(key string, segs []Segment, v any) (any, bool) {
+	n := t.writableRoot()
+	for _, seg := range segs {
+		switch seg.Kind {
+		case Static:
+			n = t.walkStatic(n, seg.Text)
+		default:
+			n = t.wildChild(n, seg)
+		}
+	}
+	if n.isLeaf() {
+		old := n.leaf.val
+		n.leaf = &leafNode{key: key, val: v}
+		return old, true
+	}
+	n.leaf = &leafNode{
+		key: key,
+		val: v,
+	}
+	t.size++
+	return nil, false
+}
+
+// walkStatic descends from n, which must be writable, along the static
+// text s, splitting and creating nodes where required. It returns the
+// writable node at which s has been fully consumed.
+func (t *Tree) walkStatic(n *node, s string) *node {
+	for len(s) > 0 {
+		// Look for the edge
+		parent := n
+		n = t.writableEdge(parent, s[0])
+
+		// No edge found, create a new one
+		if n == nil {
+			n = &node{prefix: s, gen: t.gen}
+			parent.addEdge(edge{label: s[0], node: n})
+			return n
+		}
+
+		// Determine the longest prefix match for the search key
+		common := longestPrefix(s, n.prefix)
+		if common == len(n.prefix) {
+			s = s[common:]
+			continue
+		}
+
+		// Split the node, and restore the existing node
+		child := &node{
+			prefix: s[:common],
+			gen:    t.gen,
+		}
+		parent.updateEdge(s[0], child)
+		child.addEdge(edge{label: n.prefix[common], node: n})
+		n.prefix = n.prefix[common:]
+		n = child
+		s = s[common:]
+	}
+	return n
+}
+
+// wildChild returns the writable wildcard child of n, which must be
+// writable itself, that matches seg, and will create it if it does not
+// exist yet. The wildcards are kept ordered by
+// rank, so mixed segments are tried before constrained params, which are
+// tried before plain params, and plain params are always tried before
+// catch-alls. Mixed segments with more literal text are tried first, and
+// wildcards that are otherwise alike are ordered by their Text, so the
+// order never depends on the order the routes were inserted in.
+func (t *Tree) wildChild(n *node, seg Segment) *node {
+	if c := n.findWild(seg); c != nil {
+		return t.writableWild(n, c)
+	}
+	w := &node{
+		kind:    seg.Kind,
+		ident:   seg.Text,
+		match:   seg.Match,
+		capture: seg.Capture,
+		gen:     t.gen,
+	}
+	idx := len(n.wild)
+	for i, c := range n.wild {
+		if Before(seg, Segment{Kind: c.kind, Text: c.ident, Match: c.match}) {
+			idx = i
+			break
+		}
+	}
+	n.wild = append(n.wild, nil)
+	copy(n.wild[idx+1:], n.wild[idx:])
+	n.wild[idx] = w
+	return w
+}
+
+// Before reports whether the wildcard segment a is tried before b, see
+// wildChild.
+func Before(a, b Segment) bool {
+	if ra, rb := a.rank(), b.rank(); ra != rb {
+		return ra < rb
+	}
+	if a.Kind == Mixed {
+		if la, lb := literalLen(a.Text), literalLen(b.Text); la != lb {
+			return la > lb
+		}
+	}
+	return a.Text < b.Text
+}
+
+// rank returns the order in which a wildcard segment is tried, lowest
+// first.
+func (s Segment) rank() int {
+	switch {
+	case s.Kind == Mixed:
+		return 0
+	case s.Kind == Param && s.Match != nil:
+		return 1
+	case s.Kind == Param:
+		return 2
+	}
+	return 3
+}
+
+// literalLen returns the length of the literal text of a Mixed segment,
+// leaving out its braced parameters.
+func literalLen(text string) int {
+	n, depth := 0, 0
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '{':
+			depth++
+		case text[i] == '}' && depth > 0:
+			depth--
+		case depth == 0:
+			n++
+		}
+	}
+	return n
+}
+
+// Lookup attempts to match the path against the routes that have been
+// inserted with InsertRoute. Static edges are always preferred over
+// wildcards, and the tree backtracks when a branch turns out to be a
+// dead end. The values captured by the wildcards are appended to vals
+// in the order they appear in the route. Upon success, it returns the
+// route key, value, captured values and a boolean indicating true.
+func (t *Tree) Lookup(path string, vals []string) (string, any, []string, bool) {
+	leaf, vals := t.root.lookup(path, vals)
+	if leaf == nil {
+		return "", nil, vals, false
+	}
+	return leaf.key, leaf.val, vals, true
+}
+
+func (n *node) lookup(search string, vals []string) (*leafNode, []string) {
+	// Check for key exhaustion
+	if len(search) == 0 && n.isLeaf() {
+		return n.leaf, vals
+	}
+
+	// Look for a static edge first
+	if len(search) > 0 {
+		if child := n.getEdge(search[0]); child != nil {
+			if len(search) >= len(child.prefix) && search[0:len(child.prefix)] == child.prefix {
+				// inlined version of strings.HasPrefix(search, child.prefix)
+				if leaf, found := child.lookup(search[len(child.prefix):], vals); leaf != nil {
+					return leaf, found
+				}
+			}
+		}
+	}
+
+	// Then try each one of the wildcards in turn
+	for _, w := range n.wild {
+		switch w.kind {
+		case Param:
+			end := strings.IndexByte(search, '/')
+			if end == -1 {
+				end = len(search)
+			}
+			if end == 0 || (w.match != nil && !w.match(search[:end])) {
+				continue
+			}
+			if leaf, found := w.lookup(search[end:], append(vals, search[:end])); leaf != nil {
+				return leaf, found
+			}
+		case CatchAll:
+			if w.isLeaf() {
+				return w.leaf, append(vals, search)
+			}
+		case Mixed:
+			end := strings.IndexByte(search, '/')
+			if end == -1 {
+				end = len(search)
+			}
+			if end == 0 {
+				continue
+			}
+			if vs, ok := w.capture(search[:end], vals); ok {
+				if leaf, found := w.lookup(search[end:], vs); leaf != nil {
+					return leaf, found
+				}
+			}
+		}
+	}
+	return nil, vals
+}
+
+// FindRoute is like Find, but for routes inserted with InsertRoute. The
+// segments are followed exactly, so a wildcard only matches a wildcard
+// of the same kind and text. Upon success, it returns the route key,
+// the value and a boolean indicating true.
+func (t *Tree) FindRoute(segs []Segment) (string, any, bool) {
+	n := t.root
+	for _, seg := range segs {
+		if seg.Kind != Static {
+			n = n.findWild(seg)
+		} else {
+			n = n.findStatic(seg.Text)
+		}
+		if n == nil {
+			return "", nil, false
+		}
+	}
+	if !n.isLeaf() {
+		return "", nil, false
+	}
+	return n.leaf.key, n.leaf.val, true
+}
+
+// findStatic descends from n along the static text s, and returns the
+// node at which s has been fully consumed, or nil if there is none.
+func (n *node) findStatic(s string) *node {
+	for len(s) > 0 {
+		n = n.getEdge(s[0])
+		if n == nil {
+			return nil
+		}
+		if !(len(s) >= len(n.prefix) && s[0:len(n.prefix)] == n.prefix) {
+			// inlined version of !strings.HasPrefix(s, n.prefix)
+			return nil
+		}
+		s = s[len(n.prefix):]
+	}
+	return n
+}
+
+// findWild returns the wildcard child of n that matches seg, or nil.
+func (n *node) findWild(seg Segment) *node {
+	for _, w := range n.wild {
+		if w.kind == seg.Kind && w.ident == seg.Text {
+			return w
+		}
+	}
+	return nil
+}
+
+// DeleteRoute removes the route that was inserted with InsertRoute for
+// the segments, along with any nodes that are no longer needed once it
+// is gone. Returns the old value and a boolean indicating true if the
+// route was removed.
+func (t *Tree) DeleteRoute(segs []Segment) (any, bool) {
+	if _, _, found := t.FindRoute(segs); !found {
+		return nil, false
+	}
+	path := []*node{t.writableRoot()}
+	n := t.root
+	for _, seg := range segs {
+		if seg.Kind != Static {
+			n = t.writableWild(n, n.findWild(seg))
+			path = append(path, n)
+			continue
+		}
+		for s := seg.Text; len(s) > 0; s = s[len(n.prefix):] {
+			n = t.writableEdge(n, s[0])
+			path = append(path, n)
+		}
+	}
+	leaf := n.leaf
+	n.leaf = nil
+	t.size--
+
+	// Prune the nodes that lead nowhere, starting from the bottom
+	i := len(path) - 1
+	for ; i > 0; i-- {
+		n = path[i]
+		if n.isLeaf() || len(n.edges) > 0 || len(n.wild) > 0 {
+			break
+		}
+		path[i-1].delChild(n)
+	}
+
+	// Check if we need to merge the node that is left at the bottom
+	if n = path[i]; i > 0 && !n.isLeaf() && n.canMerge() {
+		n.mergeChild()
+	}
+	return leaf.val, true
+}
+
+// delChild removes the child node c from n, whether it is a static edge
+// or a wildcard.
+func (n *node) delChild(c *node) {
+	if c.kind == Static {
+		n.delEdge(c.prefix[0])
+		return
+	}
+	for i, w := range n.wild {
+		if w == c {
+			n.wild = append(n.wild[:i], n.wild[i+1:]...)
+			return
+		}
+	}
+}
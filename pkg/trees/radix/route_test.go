@@ -0,0 +1,334 @@
+package radix
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func static(s string) Segment { return Segment{Kind: Static, Text: s} }
+func param() Segment          { return Segment{Kind: Param} }
+
+func TestTree_Lookup(t *testing.T) {
+	tree := NewTree()
+	routes := []struct {
+		key  string
+		segs []Segment
+	}{
+		{"/api/users", []Segment{static("/api/users")}},
+		{"/api/users/new", []Segment{static("/api/users/new")}},
+		{"/api/users/:id", []Segment{static("/api/users/"), param()}},
+		{"/api/users/:id/jobs/:job", []Segment{static("/api/users/"), param(), static("/jobs/"), param()}},
+		{"/api/:kind/count", []Segment{static("/api/"), param(), static("/count")}},
+	}
+	for _, r := range routes {
+		tree.InsertRoute(r.key, r.segs, r.key)
+	}
+	if tree.Len() != len(routes) {
+		t.Fatalf("Bad length, expected %v, got %v", len(routes), tree.Len())
+	}
+
+	tests := []struct {
+		path  string
+		found bool
+		key   string
+		vals  []string
+	}{
+		{"/api/users", true, "/api/users", nil},
+		{"/api/users/new", true, "/api/users/new", nil},
+		{"/api/users/42", true, "/api/users/:id", []string{"42"}},
+		{"/api/users/42/jobs/7", true, "/api/users/:id/jobs/:job", []string{"42", "7"}},
+		{"/api/users/count", true, "/api/users/:id", []string{"count"}},
+		{"/api/jobs/count", true, "/api/:kind/count", []string{"jobs"}},
+		{"/api/users/", false, "", nil},
+		{"/api/users/42/", false, "", nil},
+		{"/api/users/42/jobs", false, "", nil},
+		{"/api", false, "", nil},
+	}
+	for _, tt := range tests {
+		key, val, vals, found := tree.Lookup(tt.path, nil)
+		if found != tt.found {
+			t.Errorf("Lookup(%q): expected found=%v, got %v", tt.path, tt.found, found)
+			continue
+		}
+		if !found {
+			continue
+		}
+		if key != tt.key || val != tt.key {
+			t.Errorf("Lookup(%q): expected key %q, got %q (%v)", tt.path, tt.key, key, val)
+		}
+		if len(vals) != len(tt.vals) {
+			t.Errorf("Lookup(%q): expected values %q, got %q", tt.path, tt.vals, vals)
+			continue
+		}
+		for i := range vals {
+			if vals[i] != tt.vals[i] {
+				t.Errorf("Lookup(%q): expected values %q, got %q", tt.path, tt.vals, vals)
+				break
+			}
+		}
+	}
+}
+
+func TestTree_InsertRouteUpdate(t *testing.T) {
+	tree := NewTree()
+	segs := []Segment{static("/a/"), param()}
+	if _, updated := tree.InsertRoute("/a/:x", segs, 1); updated {
+		t.Fatalf("expected a new route")
+	}
+	old, updated := tree.InsertRoute("/a/:y", segs, 2)
+	if !updated || old != 1 {
+		t.Fatalf("expected to update the old value, got %v %v", old, updated)
+	}
+	key, val, _, _ := tree.Lookup("/a/b", nil)
+	if key != "/a/:y" || val != 2 {
+		t.Fatalf("expected the updated route, got %q %v", key, val)
+	}
+	if tree.Len() != 1 {
+		t.Fatalf("Bad length, expected %v, got %v", 1, tree.Len())
+	}
+}
+
+func TestTree_LookupCatchAll(t *testing.T) {
+	tree := NewTree()
+	tree.InsertRoute("/static/*filepath", []Segment{static("/static/"), {Kind: CatchAll}}, 1)
+	tree.InsertRoute("/static/index.html", []Segment{static("/static/index.html")}, 2)
+	tree.InsertRoute("/media/:kind/*rest", []Segment{static("/media/"), param(), static("/"), {Kind: CatchAll}}, 3)
+
+	tests := []struct {
+		path  string
+		found bool
+		val   any
+		vals  []string
+	}{
+		{"/static/index.html", true, 2, nil},
+		{"/static/css/site.css", true, 1, []string{"css/site.css"}},
+		{"/static/", true, 1, []string{""}},
+		{"/static", false, nil, nil},
+		{"/media/audio/a/b.mp3", true, 3, []string{"audio", "a/b.mp3"}},
+		{"/media/audio", false, nil, nil},
+	}
+	for _, tt := range tests {
+		_, val, vals, found := tree.Lookup(tt.path, nil)
+		if found != tt.found || val != tt.val {
+			t.Errorf("Lookup(%q): expected %v %v, got %v %v", tt.path, tt.val, tt.found, val, found)
+			continue
+		}
+		if len(vals) != len(tt.vals) {
+			t.Errorf("Lookup(%q): expected values %q, got %q", tt.path, tt.vals, vals)
+			continue
+		}
+		for i := range vals {
+			if vals[i] != tt.vals[i] {
+				t.Errorf("Lookup(%q): expected values %q, got %q", tt.path, tt.vals, vals)
+				break
+			}
+		}
+	}
+}
+
+func TestTree_LookupConstraint(t *testing.T) {
+	digits := func(s string) bool {
+		for i := 0; i < len(s); i++ {
+			if s[i] < '0' || s[i] > '9' {
+				return false
+			}
+		}
+		return true
+	}
+	tree := NewTree()
+	tree.InsertRoute("/users/:name", []Segment{static("/users/"), param()}, "name")
+	tree.InsertRoute("/users/{id:[0-9]+}", []Segment{static("/users/"), {Kind: Param, Text: "[0-9]+", Match: digits}}, "id")
+
+	tests := []struct {
+		path string
+		val  any
+	}{
+		{"/users/42", "id"},
+		{"/users/bob", "name"},
+		{"/users/4b", "name"},
+	}
+	for _, tt := range tests {
+		_, val, _, _ := tree.Lookup(tt.path, nil)
+		if val != tt.val {
+			t.Errorf("Lookup(%q): expected %v, got %v", tt.path, tt.val, val)
+		}
+	}
+}
+
+func TestTree_LookupMixed(t *testing.T) {
+	// ext captures "{}.ext" segments, such as "intro.mp3"
+	ext := func(ext string) Segment {
+		return Segment{Kind: Mixed, Text: "{}." + ext, Capture: func(s string, vals []string) ([]string, bool) {
+			if !strings.HasSuffix(s, "."+ext) || len(s) == len(ext)+1 {
+				return vals, false
+			}
+			return append(vals, s[:len(s)-len(ext)-1]), true
+		}}
+	}
+	tree := NewTree()
+	tree.InsertRoute("/audio/:id", []Segment{static("/audio/"), param()}, "id")
+	tree.InsertRoute("/audio/:name.mp3", []Segment{static("/audio/"), ext("mp3")}, "mp3")
+	tree.InsertRoute("/audio/:name.mp3/info", []Segment{static("/audio/"), ext("mp3"), static("/info")}, "info")
+	tree.InsertRoute("/audio/:name.ogg", []Segment{static("/audio/"), ext("ogg")}, "ogg")
+
+	tests := []struct {
+		path string
+		val  any
+		vals []string
+	}{
+		{"/audio/intro.mp3", "mp3", []string{"intro"}},
+		{"/audio/intro.ogg", "ogg", []string{"intro"}},
+		{"/audio/intro.mp3/info", "info", []string{"intro"}},
+		{"/audio/intro.wav", "id", []string{"intro.wav"}},
+		{"/audio/.mp3", "id", []string{".mp3"}},
+	}
+	for _, tt := range tests {
+		_, val, vals, _ := tree.Lookup(tt.path, nil)
+		if val != tt.val || strings.Join(vals, ",") != strings.Join(tt.vals, ",") {
+			t.Errorf("Lookup(%q): expected %v %q, got %v %q", tt.path, tt.val, tt.vals, val, vals)
+		}
+	}
+}
+
+func TestTree_DeleteRoute(t *testing.T) {
+	tree := NewTree()
+	routes := []struct {
+		key  string
+		segs []Segment
+	}{
+		{"/api/users", []Segment{static("/api/users")}},
+		{"/api/users/:id", []Segment{static("/api/users/"), param()}},
+		{"/api/users/:id/jobs", []Segment{static("/api/users/"), param(), static("/jobs")}},
+		{"/api/uploads", []Segment{static("/api/uploads")}},
+	}
+	for _, r := range routes {
+		tree.InsertRoute(r.key, r.segs, r.key)
+	}
+
+	if _, ok := tree.DeleteRoute([]Segment{static("/api/users/"), param(), static("/job")}); ok {
+		t.Fatalf("deleted a route that was never inserted")
+	}
+	for i, r := range routes {
+		v, ok := tree.DeleteRoute(r.segs)
+		if !ok || v != r.key {
+			t.Fatalf("DeleteRoute(%q): got %v, %v", r.key, v, ok)
+		}
+		if tree.Len() != len(routes)-i-1 {
+			t.Fatalf("Bad length, expected %v, got %v", len(routes)-i-1, tree.Len())
+		}
+		if _, _, _, ok := tree.Lookup(r.key, nil); ok {
+			t.Fatalf("Lookup(%q): found a deleted route", r.key)
+		}
+		// the remaining routes must still be found
+		for _, rest := range routes[i+1:] {
+			if _, _, ok := tree.FindRoute(rest.segs); !ok {
+				t.Fatalf("FindRoute(%q): missing after deleting %q", rest.key, r.key)
+			}
+		}
+	}
+	if len(tree.root.edges) != 0 || len(tree.root.wild) != 0 {
+		t.Fatalf("expected an empty root, got %d edges and %d wildcards", len(tree.root.edges), len(tree.root.wild))
+	}
+}
+
+func TestTree_Clone(t *testing.T) {
+	tree := NewTree()
+	tree.InsertRoute("/api/users/:id", []Segment{static("/api/users/"), param()}, 1)
+	clone := tree.Clone()
+	clone.InsertRoute("/api/users/:id", []Segment{static("/api/users/"), param()}, 2)
+	clone.InsertRoute("/api/jobs", []Segment{static("/api/jobs")}, 3)
+
+	if _, v, _, _ := tree.Lookup("/api/users/42", nil); v != 1 {
+		t.Fatalf("original changed by the clone, got %v", v)
+	}
+	if _, _, _, ok := tree.Lookup("/api/jobs", nil); ok {
+		t.Fatalf("original changed by the clone, found /api/jobs")
+	}
+	if _, v, _, _ := clone.Lookup("/api/users/42", nil); v != 2 {
+		t.Fatalf("clone not updated, got %v", v)
+	}
+	if tree.Len() != 1 || clone.Len() != 2 {
+		t.Fatalf("Bad length, got %d and %d", tree.Len(), clone.Len())
+	}
+}
+
+func TestTree_CloneWhileReading(t *testing.T) {
+	tree := NewTree()
+	tree.InsertRoute("/api/users/:id", []Segment{static("/api/users/"), param()}, 1)
+
+	// readers go on looking up the tree while it is cloned, the way
+	// requests read a router's routes while a new one is registered, so
+	// that the race detector catches Clone writing anything they read
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if _, v, _, _ := tree.Lookup("/api/users/42", nil); v != 1 {
+					t.Errorf("Lookup while cloning: got %v", v)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		clone := tree.Clone()
+		clone.InsertRoute("/api/users/:id", []Segment{static("/api/users/"), param()}, 2)
+		clone.Insert(fmt.Sprintf("/api/jobs/%d", i), i)
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestTree_CloneChanges(t *testing.T) {
+	keys := []string{"/a", "/ab", "/abc", "/abd", "/b", "/ba", "/bab", "/c"}
+	tree := NewTree()
+	for i, k := range keys {
+		tree.Insert(k, i)
+	}
+	tree.InsertRoute("/users/:id", []Segment{static("/users/"), param()}, "user")
+
+	// every change to the clone, including splits, merges and prunes,
+	// must leave the original as it was, and the other way around
+	clone := tree.Clone()
+	clone.Delete("/ab")
+	clone.Delete("/abc")
+	clone.DeletePrefix("/b")
+	clone.Insert("/abe", "new")
+	clone.DeleteRoute([]Segment{static("/users/"), param()})
+	tree.Insert("/a", "changed")
+
+	for i, k := range keys {
+		want := any(i)
+		if k == "/a" {
+			want = "changed"
+		}
+		if v, ok := tree.Find(k); !ok || v != want {
+			t.Fatalf("Find(%q) on the original: got %v, want %v", k, v, want)
+		}
+	}
+	if _, v, _, _ := tree.Lookup("/users/42", nil); v != "user" {
+		t.Fatalf("original changed by the clone, got %v", v)
+	}
+	want := map[string]any{"/a": 0, "/abd": 3, "/abe": "new", "/c": 7}
+	got := map[string]any{}
+	clone.Walk(func(k string, v any) bool {
+		got[k] = v
+		return false
+	})
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("clone: got %v, want %v", got, want)
+	}
+	if tree.Len() != len(keys)+1 || clone.Len() != len(want) {
+		t.Fatalf("Bad length, got %d and %d", tree.Len(), clone.Len())
+	}
+}